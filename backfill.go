@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-colly/store"
+	"go-colly/util"
+	"log"
+)
+
+// backfill 把自选列表的 K 线历史补全到 sqlite 的 kline 表
+// go-colly backfill -period DAY
+func backfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	periodFlag := fs.String("period", string(util.PeriodDay), "K-line period: MIN1, MIN5, MIN15, MIN30, MIN60, DAY, WEEK, MONTH")
+	fs.Parse(args)
+
+	period, err := util.ParsePeriod(*periodFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conf := loadConfig()
+
+	repo, err := store.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	for _, ins := range conf.Watchlist() {
+		n, err := util.BackfillKlines(ctx, repo, ins, period)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		fmt.Printf("%s %s %s: %d bars\n", ins.Symbol(), ins.Name, period, n)
+	}
+}
+
+// checkbars 用本地保存的日 K 合成周 K 或月 K，和服务端保存的对比
+// go-colly checkbars -period WEEK
+func checkbars(args []string) {
+	fs := flag.NewFlagSet("checkbars", flag.ExitOnError)
+	periodFlag := fs.String("period", string(util.PeriodWeek), "WEEK or MONTH")
+	fs.Parse(args)
+
+	period, err := util.ParsePeriod(*periodFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conf := loadConfig()
+
+	repo, err := store.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	for _, ins := range conf.Watchlist() {
+		days, err := repo.LoadKlines(ctx, ins.Symbol(), util.PeriodDay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server, err := repo.LoadKlines(ctx, ins.Symbol(), period)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(days) == 0 || len(server) == 0 {
+			fmt.Printf("%s %s: run backfill for DAY and %s first\n", ins.Symbol(), ins.Name, period)
+			continue
+		}
+
+		local, err := util.AggregateKlines(days, period)
+		if err != nil {
+			log.Fatal(err)
+		}
+		diffs := util.CompareKlines(local, server, period)
+		fmt.Printf("%s %s: %d %s bars, %d differences\n", ins.Symbol(), ins.Name, len(local), period, len(diffs))
+		for _, d := range diffs {
+			fmt.Printf("  %s %-7s local=%.3f server=%.3f\n", d.Key, d.Field, d.Local, d.Server)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package calendar 沪深交易所的交易日历
+package calendar
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed holidays.txt
+var embedded string
+
+const dateLayout = "2006-01-02"
+
+var (
+	shanghai     *time.Location
+	shanghaiOnce sync.Once
+)
+
+// Location A 股所在时区，系统没有时区数据时退回 UTC+8
+func Location() *time.Location {
+	shanghaiOnce.Do(func() {
+		loc, err := time.LoadLocation("Asia/Shanghai")
+		if err != nil {
+			loc = time.FixedZone("CST", 8*3600)
+		}
+		shanghai = loc
+	})
+	return shanghai
+}
+
+// Date t 在北京时间的零点
+func Date(t time.Time) time.Time {
+	t = t.In(Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location())
+}
+
+// FromUnix KlineData.TradingDay 这样的 unix 秒转成北京时间的日期
+func FromUnix(sec int64) time.Time {
+	return Date(time.Unix(sec, 0))
+}
+
+// Calendar 交易日历：周一到周五，去掉休市日期
+type Calendar struct {
+	holidays map[string]bool // 20241001
+}
+
+// New 用休市日期创建日历
+func New(holidays []time.Time) *Calendar {
+	c := &Calendar{holidays: make(map[string]bool, len(holidays))}
+	for _, d := range holidays {
+		c.holidays[key(d)] = true
+	}
+	return c
+}
+
+var (
+	defaultCal  *Calendar
+	defaultOnce sync.Once
+)
+
+// Default 编译时嵌入的 holidays.txt
+func Default() *Calendar {
+	defaultOnce.Do(func() {
+		c, err := Parse(strings.NewReader(embedded), "holidays.txt")
+		if err != nil {
+			panic(err)
+		}
+		defaultCal = c
+	})
+	return defaultCal
+}
+
+// Load 读取休市日期文件
+func Load(path string) (*Calendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file, path)
+}
+
+// Parse 每行一个 YYYY-MM-DD，# 后面是注释
+func Parse(r io.Reader, name string) (*Calendar, error) {
+	c := &Calendar{holidays: make(map[string]bool)}
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		d, err := time.ParseInLocation(dateLayout, line, Location())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q", name, lineNo, line)
+		}
+		c.holidays[key(d)] = true
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func key(t time.Time) string {
+	return t.In(Location()).Format("20060102")
+}
+
+// IsTradingDay t 所在的那一天（北京时间）是否开市
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	t = t.In(Location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[key(t)]
+}
+
+// maxGap 查找前后交易日时最多跨越的天数，春节加周末也不会超过
+const maxGap = 30
+
+// Next t 之后的下一个交易日，不包括 t 当天
+func (c *Calendar) Next(t time.Time) time.Time {
+	d := Date(t)
+	for i := 0; i < maxGap; i++ {
+		d = d.AddDate(0, 0, 1)
+		if c.IsTradingDay(d) {
+			return d
+		}
+	}
+	return time.Time{}
+}
+
+// Prev t 之前的上一个交易日，不包括 t 当天
+func (c *Calendar) Prev(t time.Time) time.Time {
+	d := Date(t)
+	for i := 0; i < maxGap; i++ {
+		d = d.AddDate(0, 0, -1)
+		if c.IsTradingDay(d) {
+			return d
+		}
+	}
+	return time.Time{}
+}
+
+// Between from 到 to 之间的交易日，两端都包括
+func (c *Calendar) Between(from, to time.Time) []time.Time {
+	var days []time.Time
+	end := Date(to)
+	for d := Date(from); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if c.IsTradingDay(d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// Holidays 周末以外的休市日期，按时间排序
+func (c *Calendar) Holidays() []time.Time {
+	days := make([]time.Time, 0, len(c.holidays))
+	for k := range c.holidays {
+		d, err := time.ParseInLocation("20060102", k, Location())
+		if err == nil {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// LastYear 休市日期覆盖到哪一年，没有休市日期时为 0。之后的节假日不会休市，需要更新 holidays.txt
+func (c *Calendar) LastYear() int {
+	last := ""
+	for k := range c.holidays {
+		if k > last {
+			last = k
+		}
+	}
+	if last == "" {
+		return 0
+	}
+	year, _ := strconv.Atoi(last[:4])
+	return year
+}
+
+// Covers t 所在的年份是否在休市日期的范围内
+func (c *Calendar) Covers(t time.Time) bool {
+	return t.In(Location()).Year() <= c.LastYear()
+}
+
+// Write 按 holidays.txt 的格式输出
+func (c *Calendar) Write(w io.Writer) error {
+	year := 0
+	for _, d := range c.Holidays() {
+		if d.Year() != year {
+			if year != 0 {
+				if _, err := fmt.Fprintln(w); err != nil {
+					return err
+				}
+			}
+			year = d.Year()
+			if _, err := fmt.Fprintf(w, "# %d\n", year); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, d.Format(dateLayout)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild 用有日 K 的交易日重新生成日历：第一个到最后一个交易日之间，
+// 不在 days 中的工作日就是休市日。这个范围以外沿用 base 的休市日期，base 可以为 nil
+func Rebuild(days []time.Time, base *Calendar) (*Calendar, error) {
+	traded := make(map[string]bool, len(days))
+	var first, last time.Time
+	for _, d := range days {
+		d = Date(d)
+		traded[key(d)] = true
+		if first.IsZero() || d.Before(first) {
+			first = d
+		}
+		if d.After(last) {
+			last = d
+		}
+	}
+	if len(traded) == 0 {
+		return nil, errors.New("no trading days, run backfill first")
+	}
+
+	c := &Calendar{holidays: make(map[string]bool)}
+	if base != nil {
+		for k := range base.holidays {
+			if k < key(first) || k > key(last) {
+				c.holidays[k] = true
+			}
+		}
+	}
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday && !traded[key(d)] {
+			c.holidays[key(d)] = true
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,244 @@
+package calendar
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation(dateLayout, s, Location())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func days(t *testing.T, list ...string) []time.Time {
+	t.Helper()
+	out := make([]time.Time, 0, len(list))
+	for _, s := range list {
+		out = append(out, day(t, s))
+	}
+	return out
+}
+
+// testCalendar 2024 年劳动节和国庆节
+func testCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	c, err := Parse(strings.NewReader(`# 2024
+2024-05-01 # 劳动节
+2024-05-02
+2024-05-03
+
+2024-10-01 # 国庆节
+2024-10-02
+2024-10-03
+2024-10-04
+2024-10-07
+`), "test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestParse(t *testing.T) {
+	c := testCalendar(t)
+	want := days(t, "2024-05-01", "2024-05-02", "2024-05-03", "2024-10-01", "2024-10-02", "2024-10-03", "2024-10-04", "2024-10-07")
+	if got := c.Holidays(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Holidays = %v, want %v", got, want)
+	}
+	if c.LastYear() != 2024 {
+		t.Errorf("LastYear = %d, want 2024", c.LastYear())
+	}
+
+	tests := []struct {
+		name, text, wantErr string
+	}{
+		{"bad date", "2024-05-01\n2024-13-01\n", `bad.txt:2: invalid date "2024-13-01"`},
+		{"slashes", "# comment\n\n2024/05/01 # 劳动节\n", `bad.txt:3: invalid date "2024/05/01"`},
+		{"two dates on a line", "2024-05-01 2024-05-02\n", `bad.txt:1: invalid date "2024-05-01 2024-05-02"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.text), "bad.txt")
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	empty, err := Parse(strings.NewReader("# nothing\n"), "empty.txt")
+	if err != nil || len(empty.Holidays()) != 0 || empty.LastYear() != 0 || empty.Covers(day(t, "2024-05-17")) {
+		t.Errorf("empty calendar = %v, %v", empty, err)
+	}
+}
+
+func TestIsTradingDay(t *testing.T) {
+	c := testCalendar(t)
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-04-30", true},
+		{"2024-05-01", false}, // 劳动节
+		{"2024-05-04", false}, // 周六
+		{"2024-05-05", false}, // 周日
+		{"2024-05-06", true},
+		{"2024-05-11", false}, // 调休的周六交易所也休市
+		{"2024-10-07", false},
+		{"2024-10-08", true},
+	}
+	for _, tt := range tests {
+		if got := c.IsTradingDay(day(t, tt.at)); got != tt.want {
+			t.Errorf("IsTradingDay(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	// 按北京时间的日期判断：UTC 4 月 30 日 16:00 是北京时间 5 月 1 日零点
+	if c.IsTradingDay(time.Date(2024, 4, 30, 16, 0, 0, 0, time.UTC)) {
+		t.Error("2024-04-30 16:00 UTC is 2024-05-01 in Shanghai")
+	}
+}
+
+func TestNextPrev(t *testing.T) {
+	c := testCalendar(t)
+	tests := []struct {
+		at, next, prev string
+	}{
+		{"2024-05-17", "2024-05-20", "2024-05-16"}, // 周五
+		{"2024-05-18", "2024-05-20", "2024-05-17"}, // 周六
+		{"2024-04-30", "2024-05-06", "2024-04-29"}, // 劳动节加周末
+		{"2024-05-02", "2024-05-06", "2024-04-30"},
+		{"2024-05-06", "2024-05-07", "2024-04-30"},
+		{"2024-09-30", "2024-10-08", "2024-09-27"}, // 国庆节
+		{"2024-10-08", "2024-10-09", "2024-09-30"},
+	}
+	for _, tt := range tests {
+		at := day(t, tt.at)
+		if got := c.Next(at); !got.Equal(day(t, tt.next)) {
+			t.Errorf("Next(%s) = %s, want %s", tt.at, got.Format(dateLayout), tt.next)
+		}
+		if got := c.Prev(at); !got.Equal(day(t, tt.prev)) {
+			t.Errorf("Prev(%s) = %s, want %s", tt.at, got.Format(dateLayout), tt.prev)
+		}
+		// 一天中的任何时间都一样
+		if got := c.Next(at.Add(23 * time.Hour)); !got.Equal(day(t, tt.next)) {
+			t.Errorf("Next(%s 23:00) = %s, want %s", tt.at, got.Format(dateLayout), tt.next)
+		}
+	}
+
+	// 连续休市超过 maxGap 时返回零值
+	long := make([]time.Time, 0, 60)
+	for d := day(t, "2024-06-01"); d.Before(day(t, "2024-07-31")); d = d.AddDate(0, 0, 1) {
+		long = append(long, d)
+	}
+	if got := New(long).Next(day(t, "2024-05-31")); !got.IsZero() {
+		t.Errorf("Next over a long closure = %s, want zero", got)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	c := testCalendar(t)
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"2024-04-29", "2024-05-07", []string{"2024-04-29", "2024-04-30", "2024-05-06", "2024-05-07"}},
+		{"2024-05-01", "2024-05-05", nil}, // 全部休市
+		{"2024-09-27", "2024-10-08", []string{"2024-09-27", "2024-09-30", "2024-10-08"}},
+		{"2024-05-17", "2024-05-17", []string{"2024-05-17"}}, // 两端都包括
+		{"2024-05-20", "2024-05-17", nil},                    // from 晚于 to
+	}
+	for _, tt := range tests {
+		got := c.Between(day(t, tt.from), day(t, tt.to))
+		var want []time.Time
+		if tt.want != nil {
+			want = days(t, tt.want...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Between(%s, %s) = %v, want %v", tt.from, tt.to, got, want)
+		}
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	base := testCalendar(t)
+	// 日 K 从 2024-04-26 到 2024-05-10，5 月 1 日至 3 日没有日 K，5 月 8 日停牌或者缺数据
+	traded := days(t,
+		"2024-04-26",
+		"2024-04-29", "2024-04-30",
+		"2024-05-06", "2024-05-07", "2024-05-09",
+		"2024-05-10",
+	)
+	// 顺序和重复都不影响
+	traded = append(traded, day(t, "2024-04-30").Add(10*time.Hour))
+	traded[0], traded[len(traded)-2] = traded[len(traded)-2], traded[0]
+
+	c, err := Rebuild(traded, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := days(t,
+		"2024-05-01", "2024-05-02", "2024-05-03",
+		"2024-05-08",                                                         // 范围内缺少的工作日
+		"2024-10-01", "2024-10-02", "2024-10-03", "2024-10-04", "2024-10-07", // 范围外沿用 base
+	)
+	if got := c.Holidays(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Holidays = %v\nwant %v", got, want)
+	}
+	// 周末不写入
+	if c.IsTradingDay(day(t, "2024-05-04")) {
+		t.Error("weekend became a trading day")
+	}
+
+	// base 为 nil 时只有范围内的休市日
+	c, err = Rebuild(traded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Holidays(); len(got) != 4 || c.LastYear() != 2024 {
+		t.Errorf("Holidays without base = %v", got)
+	}
+
+	if _, err := Rebuild(nil, base); err == nil {
+		t.Error("Rebuild without days succeeded")
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	var buf bytes.Buffer
+	holidays := days(t, "2024-10-01", "2025-01-01", "2025-01-28")
+	if err := New(holidays).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# 2024\n2024-10-01\n\n# 2025\n2025-01-01\n2025-01-28\n"
+	if buf.String() != want {
+		t.Errorf("Write = %q, want %q", buf.String(), want)
+	}
+	c, err := Parse(&buf, "written.txt")
+	if err != nil || !reflect.DeepEqual(c.Holidays(), holidays) {
+		t.Errorf("Parse(Write) = %v, %v", c.Holidays(), err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	for _, d := range c.Holidays() {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			t.Errorf("holidays.txt lists a weekend: %s", d.Format(dateLayout))
+		}
+	}
+	for _, year := range []int{2024, 2025, 2026} {
+		if !c.Covers(time.Date(year, 12, 31, 0, 0, 0, 0, Location())) {
+			t.Errorf("holidays.txt does not cover %d", year)
+		}
+	}
+	if c.IsTradingDay(day(t, "2024-10-01")) || !c.IsTradingDay(day(t, "2024-10-08")) {
+		t.Error("National Day 2024 not in holidays.txt")
+	}
+}
@@ -1,23 +1,98 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"flag"
 	"fmt"
+	"go-colly/store"
 	"go-colly/util"
 	"log"
 	"math/rand"
-	"reflect"
+	"os"
 	"time"
 
 	"github.com/gocolly/colly"
 )
 
+var (
+	workers  = flag.Int("workers", 0, "number of concurrent quote requests, overrides settings.workers")
+	deadline = flag.Duration("deadline", 0, "deadline of one refresh round, overrides settings.deadline")
+	depth    = flag.String("depth", "", "show the order book of one symbol under the table, e.g. sh510300")
+	theme    = flag.String("theme", "", "colour theme: cn, western, colorblind, mono; overrides settings.theme")
+	plain    = flag.Bool("plain", false, "print the table every round instead of the full-screen monitor")
+)
+
 func main() {
-	fun3()
+	flag.Parse()
+	util.FetchOverride = util.FetchOptions{Workers: *workers, Deadline: *deadline}
+	if *theme != "" {
+		if _, err := util.GetTheme(*theme); err != nil {
+			log.Fatal(err)
+		}
+		util.ThemeOverride = *theme
+	}
+
+	switch flag.Arg(0) {
+	case "config-migrate":
+		configMigrate()
+	case "backfill":
+		backfill(flag.Args()[1:])
+	case "checkbars":
+		checkbars(flag.Args()[1:])
+	case "calendar":
+		tradingDays(flag.Args()[1:])
+	case "migrate":
+		migrateDB(flag.Args()[1:])
+	case "db":
+		database(flag.Args()[1:])
+	case "report":
+		report(flag.Args()[1:])
+	default:
+		if _, err := util.DefaultConfigWatcher.Config(); err != nil {
+			log.Fatal(err)
+		}
+		snapshots = openSnapshots()
+
+		// 终端中使用全屏监控，输出到管道或文件时逐轮打印表格
+		if *plain || !util.IsTerminal() {
+			fun3()
+			return
+		}
+		if err := runTUI(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// snapshots 保存每一轮的报价，数据库打开失败时为 nil
+var snapshots *util.SnapshotWriter
+
+func openSnapshots() *util.SnapshotWriter {
+	repo, err := store.Open()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	w, err := util.NewSnapshotWriter(context.Background(), repo)
+	if err != nil {
+		log.Println("quote snapshots disabled:", err)
+		return nil
+	}
+	return w
+}
+
+// saveSnapshots 写入一轮报价，失败时只打印日志
+func saveSnapshots(at time.Time, result []util.KlineData) {
+	if snapshots == nil {
+		return
+	}
+	if _, err := snapshots.Write(context.Background(), at, result); err != nil {
+		log.Println("save quote snapshots:", err)
+	}
 }
 
 func fun1() {
-	c := colly.NewCollector()
+	c := util.NewCollector(context.Background())
 
 	c.OnHTML("html", func(e *colly.HTMLElement) {
 
@@ -36,257 +111,148 @@ func fun1() {
 func fun3() {
 	// cmdToken := util.ParseTokenFromParam()
 
-	cmdToken := util.GetTokenFromWebsite()
-	if cmdToken == "" {
-		// log.Fatalln("token is empty") // 获取失败了
-	}
+	// token 由 util.DefaultTokenManager 管理：过期前自动刷新，失效时重新登录
+	cmdToken := ""
 
 	var FormatBool bool
+	var result []util.KlineData
 	for {
-		result, err := util.GetStockData(cmdToken)
-		if err != nil {
-			log.Fatal(err)
+		// 非交易时段不拉取，只刷新底部的交易状态
+		now := time.Now()
+		if util.DefaultScheduler.Due(now) {
+			var err error
+			result, err = util.GetStockData(cmdToken)
+			if err != nil {
+				log.Fatal(err)
+			}
+			saveSnapshots(now, result)
 		}
 
-		if FormatBool {
-			fmt.Println(util.RefreshTable(util.BuildTable(result)))
+		out := util.BuildTable(result, util.DefaultConfigWatcher.Notice(), util.DefaultScheduler.Status(now))
+		if *depth != "" {
+			out += "\n" + util.DepthPanel(result, *depth)
+		}
+		// 输出到管道或文件时不用光标控制符覆盖上一次的表格
+		if FormatBool && util.IsTerminal() {
+			fmt.Println(util.RefreshTable(out))
 		} else {
-			fmt.Println(util.BuildTable(result))
+			fmt.Println(out)
 			FormatBool = true
 		}
 
-		time.Sleep(5 * time.Second)
+		time.Sleep(util.DefaultScheduler.Wait(time.Now(), util.ActiveSettings().PollInterval))
 	}
 }
 
-var period = []string{
-	"2024Q1",
-	"2024Q2",
-	"2024Q3",
-	"2024Q4",
-	"2024",
-	"2023Q1",
-	"2023Q2",
-	"2023Q3",
-	"2023Q4",
-	"2023",
-	"2022Q1",
-	"2022Q2",
-	"2022Q3",
-	"2022Q4",
-	"2022",
-	"2021Q1",
-	"2021Q2",
-	"2021Q3",
-	"2021Q4",
-	"2021",
-	"2020Q1",
-	"2020Q2",
-	"2020Q3",
-	"2020Q4",
-	"2020",
-	"2019Q1",
-	"2019Q2",
-	"2019Q3",
-	"2019Q4",
-	"2019",
-	"2018Q1",
-	"2018Q2",
-	"2018Q3",
-	"2018Q4",
-	"2018",
+// loadConfig 读取配置文件并应用其中的设置
+func loadConfig() util.Config {
+	conf, err := util.ParseConfigFile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	util.DefaultTokenManager.Seed(conf.Token)
+	return conf
 }
 
-type Item struct {
-	Id         int
-	StockName  string
-	TargetName string
-	P2018Q1    float64
-	P2018Q2    float64
-	P2018Q3    float64
-	P2018Q4    float64
-	P2018      float64
-	P2019Q1    float64
-	P2019Q2    float64
-	P2019Q3    float64
-	P2019Q4    float64
-	P2019      float64
-	P2020Q1    float64
-	P2020Q2    float64
-	P2020Q3    float64
-	P2020Q4    float64
-	P2020      float64
-	P2021Q1    float64
-	P2021Q2    float64
-	P2021Q3    float64
-	P2021Q4    float64
-	P2021      float64
-	P2022Q1    float64
-	P2022Q2    float64
-	P2022Q3    float64
-	P2022Q4    float64
-	P2022      float64
-	P2023Q1    float64
-	P2023Q2    float64
-	P2023Q3    float64
-	P2023Q4    float64
-	P2023      float64
-	P2024Q1    float64
-	P2024Q2    float64
-	P2024Q3    float64
-	P2024Q4    float64
-	P2024      float64
+// configMigrate 把 code.txt 转成 watchlist.yaml，watchlist.yaml 已存在时不覆盖
+func configMigrate() {
+	if _, err := os.Stat("watchlist.yaml"); err == nil {
+		log.Fatal("watchlist.yaml already exists")
+	}
+	if err := util.MigrateCodeFile("code.txt", "watchlist.yaml"); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("code.txt migrated to watchlist.yaml")
 }
 
-func fun4() {
-	sqldb, err := util.CreateSqlite3()
+// report 把财报数据导出为 xlsx，列为 stock_data 中出现过的财报期
+// go-colly report -from 2020 -to 2024Q2 -order asc
+func report(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	from := fs.String("from", "", "first report period, e.g. 2020 or 2020Q1; empty for the earliest")
+	to := fs.String("to", "", "last report period, e.g. 2024 or 2024Q2; empty for the latest")
+	order := fs.String("order", "desc", "period order: asc or desc")
+	limit := fs.Int("limit", 0, "number of stocks, 0 for all")
+	fs.Parse(args)
+
+	if *order != "asc" && *order != "desc" {
+		log.Fatalf("unknown order %q, want asc or desc", *order)
+	}
+	periods, err := store.NewPeriodRange(*from, *to, *order == "desc")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer sqldb.Close()
-
-	target_names := getTargetNames(sqldb)
-
-	sql := `
-	SELECT
-		id
-	FROM
-		stock
-	WHERE status = 1
-	LIMIT 2
-	`
 
-	results, err := sqldb.Query(sql)
+	loadConfig()
+	repo, err := store.Open()
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer repo.Close()
 
-	stockList := make([]Item, 0)
-	defer results.Close()
-
-	for results.Next() {
-		var id int
-		err := results.Scan(&id)
-		if err != nil {
-			fmt.Println("scan error: ", err)
-			break
-		}
-		lt := one(sqldb, id, target_names)
-		stockList = append(stockList, lt...)
+	ctx := context.Background()
+	stocks, err := repo.Stocks(ctx, *limit)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// util.PrettyPrint(stockList)
-
-	// 字段标题，和 item 字段一一对应
-	headers := []string{"ID", "名称", "指标"}
-	headers = append(headers, period...)
-	title := "财报数据"
-	filepath := "file"
-	filename := fmt.Sprintf("stock-%d.xlsx", time.Now().Unix())
-
-	fmt.Println(util.OutPutDataWithXLSX(stockList, headers, title, filepath, filename, len(target_names)))
-}
-
-func one(sqldb *sql.DB, stockId int, target_names []string) []Item {
-	sql := `
-	SELECT
-		b.id,
-		b.name AS stock_name,
-		a.period,
-		c.name AS target_name,
-		a.data
-	FROM
-		stock_data a
-	INNER JOIN stock b ON a.stock_id = b.id
-	INNER JOIN stock_target c ON a.target_id = c.id
-	WHERE a.stock_id = ?
-	ORDER BY
-		a.target_id ASC
-	`
-
-	results, err := sqldb.Query(sql, stockId)
+	pivot, err := store.LoadPivot(ctx, repo, stocks, periods)
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, p := range pivot.Skipped {
+		log.Printf("skip unknown report period %q", p)
+	}
+	if len(pivot.Periods) == 0 {
+		log.Fatal("no report periods in range")
+	}
 
-	list := make(map[string]Item)
-	res := make([]Item, 0)
-	defer results.Close()
-
-	for results.Next() {
-		var id int
-		var stock_name, period, target_name string
-		var data float64
-		err := results.Scan(&id, &stock_name, &period, &target_name, &data)
-		if err != nil {
-			fmt.Println("scan error: ", err)
-			break
-		}
-
-		if _, ok := list[target_name]; !ok {
-			list[target_name] = Item{
-				Id:         id,
-				StockName:  stock_name,
-				TargetName: target_name,
-			}
-		}
-
-		line := list[target_name]
-		reflect.ValueOf(&line).Elem().FieldByName("P" + period).SetFloat(data)
+	// util.PrettyPrint(pivot)
 
-		list[target_name] = line
-	}
+	headers, rows := pivot.Table()
+	title := "财报数据"
+	filepath := util.ActiveSettings().OutputDir
+	filename := fmt.Sprintf("stock-%d.xlsx", time.Now().Unix())
 
-	for _, target_name := range target_names {
-		if line, ok := list[target_name]; ok {
-			res = append(res, line)
-		}
+	// 同一只股票的 ID、名称合并
+	if err := util.OutPutDataWithXLSX(rows, headers, title, filepath, filename, 2); err != nil {
+		log.Fatal(err)
 	}
-
-	return res
+	fmt.Printf("%d rows, %d periods written to %s/%s\n", len(rows), len(pivot.Periods), filepath, filename)
 }
 
-func getTargetNames(sqldb *sql.DB) []string {
-	sql := "select name from stock_target ORDER BY id asc"
-	targets, err := sqldb.Query(sql)
+func createdata() {
+	repo, err := store.Open()
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		return
 	}
+	defer repo.Close()
 
-	target_names := make([]string, 0)
-	defer targets.Close()
-
-	for targets.Next() {
-		var name string
-		err := targets.Scan(&name)
-		if err != nil {
-			fmt.Println("scan error: ", err)
-			break
+	// 2018 到 2024 年的季报和年报
+	period := make([]string, 0)
+	for year := 2018; year <= 2024; year++ {
+		for q := 1; q <= 4; q++ {
+			period = append(period, fmt.Sprintf("%dQ%d", year, q))
 		}
-		target_names = append(target_names, name)
+		period = append(period, fmt.Sprint(year))
 	}
 
-	return target_names
-}
-
-func createdata() {
-	sqldb, _ := util.CreateSqlite3()
-	defer sqldb.Close()
-
 	target := []int{1, 2, 3, 4, 5, 6, 7, 8, 9}
 	stock := make([]int, 3)
-	format := "insert into stock_data(stock_id, period, target_id, data) values(?,?,?,?)"
+	points := make([]store.DataPoint, 0)
 	for k := range stock {
 		stockId := k + 1
 		for _, p := range period {
 			for _, t := range target {
 				d := int64(100 * rand.Float64())
-				_, err := sqldb.Exec(format, stockId, p, t, d)
-				if err != nil {
-					fmt.Println(err)
-					return
-				}
+				points = append(points, store.DataPoint{StockID: stockId, Period: p, TargetID: t, Value: float64(d)})
 			}
 		}
 	}
+	if err := repo.InsertStockData(context.Background(), points); err != nil {
+		fmt.Println(err)
+	}
 }
@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// goMigrations 需要判断的迁移
+var goMigrations = []Migration{
+	// 删掉的表无法恢复，回滚时只删除版本记录
+	{Version: 2, Name: "drop_old_tables", Up: dropOldTables, Down: func(tx *sql.Tx, d Dialect) error { return nil }},
+}
+
+// oldTable 手工迁移留下的备份表，如 _stock_old_20240517、_stock_data_old_20240517_1，第一个分组为原来的表名
+var oldTable = regexp.MustCompile(`^_(\w+?)_old_\d{8}(_\d+)?$`)
+
+// dropOldTables 删除手工迁移留下的 _xxx_old_YYYYMMDD 表。
+// 空表直接删除；其他的只有原表存在、备份表的列原表都有、并且备份表的每一行（包括重复的行）在原表中都有时才删除。
+// 保留的表都打印原因
+func dropOldTables(tx *sql.Tx, d Dialect) error {
+	rows, err := tx.Query(d.tablesQuery())
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	exists := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		exists[t] = true
+	}
+
+	for _, t := range tables {
+		match := oldTable.FindStringSubmatch(t)
+		if match == nil {
+			continue
+		}
+		live := match[1]
+
+		reason, err := keepReason(tx, t, live, exists[live])
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			log.Printf("keep %s: %s", t, reason)
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE "%s"`, t)); err != nil {
+			return err
+		}
+		log.Printf("dropped %s", t)
+	}
+	return nil
+}
+
+// keepReason 备份表 t 不能删除的原因，可以删除时返回空
+func keepReason(tx *sql.Tx, t, live string, liveExists bool) (string, error) {
+	oldRows, err := countRows(tx, t)
+	if err != nil {
+		return "", err
+	}
+	if oldRows == 0 {
+		return "", nil
+	}
+	if !liveExists {
+		return fmt.Sprintf("%d rows and table %s does not exist", oldRows, live), nil
+	}
+
+	oldCols, err := columns(tx, t)
+	if err != nil {
+		return "", err
+	}
+	liveCols, err := columns(tx, live)
+	if err != nil {
+		return "", err
+	}
+	inLive := make(map[string]bool, len(liveCols))
+	for _, c := range liveCols {
+		inLive[c] = true
+	}
+	var shared, extra []string
+	for _, c := range oldCols {
+		if inLive[c] {
+			shared = append(shared, `"`+c+`"`)
+		} else {
+			extra = append(extra, c)
+		}
+	}
+	if len(extra) > 0 {
+		return fmt.Sprintf("columns %s are not in %s", strings.Join(extra, ", "), live), nil
+	}
+
+	liveRows, err := countRows(tx, live)
+	if err != nil {
+		return "", err
+	}
+	if oldRows > liveRows {
+		return fmt.Sprintf("%d rows, %s has only %d", oldRows, live, liveRows), nil
+	}
+
+	// EXCEPT 会合并重复的行，给相同的行编号后再比较，备份表中重复的行在原表中也要有同样多
+	var missing int
+	cols := strings.Join(shared, ", ")
+	numbered := `SELECT %[1]s, ROW_NUMBER() OVER (PARTITION BY %[1]s) AS n FROM "%[2]s"`
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM (%s EXCEPT %s) diff`,
+		fmt.Sprintf(numbered, cols, t), fmt.Sprintf(numbered, cols, live))
+	if err := tx.QueryRow(query).Scan(&missing); err != nil {
+		return "", fmt.Errorf("compare %s with %s: %w", t, live, err)
+	}
+	if missing > 0 {
+		return fmt.Sprintf("%d rows are not in %s", missing, live), nil
+	}
+	return "", nil
+}
+
+func countRows(tx *sql.Tx, table string) (int, error) {
+	var n int
+	if err := tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count %s: %w", table, err)
+	}
+	return n, nil
+}
+
+// columns 表的所有列名，按表中的顺序
+func columns(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT * FROM "%s" WHERE 1 = 0`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
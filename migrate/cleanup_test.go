@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteDropOldTables(t *testing.T) {
+	sqldb, err := sql.Open(SQLite.DriverName(), filepath.Join(t.TempDir(), "stock.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	testDropOldTables(t, sqldb, SQLite)
+}
+
+// TestPostgresDropOldTables 设置 PG_DSN 时执行，会删除库中所有的表
+func TestPostgresDropOldTables(t *testing.T) {
+	dsn := os.Getenv("PG_DSN")
+	if dsn == "" {
+		t.Skip("PG_DSN not set")
+	}
+	sqldb, err := sql.Open(Postgres.DriverName(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	resetPostgres(t, sqldb)
+	testDropOldTables(t, sqldb, Postgres)
+}
+
+func testDropOldTables(t *testing.T, sqldb *sql.DB, d Dialect) {
+	if _, err := Up(sqldb, d); err != nil {
+		t.Fatal(err)
+	}
+	setup := []string{
+		`INSERT INTO stock (name, code, place) VALUES ('平安银行', '000001', 'sz'), ('浦发银行', '600000', 'sh')`,
+		`INSERT INTO stock_target (name) VALUES ('pe')`,
+
+		// 空表，多一列 target：删除
+		`CREATE TABLE _stock_data_old_20240517 (stock_id INTEGER, target TEXT, data REAL)`,
+		// 空表，target 改名为 name 前的结构：删除
+		`CREATE TABLE _stock_target_old_20240517 (id INTEGER, target TEXT)`,
+		// 和原表相同：删除
+		`CREATE TABLE _stock_old_20240517 AS SELECT * FROM stock`,
+		// 有一行不在原表中：保留
+		`CREATE TABLE _stock_old_20240518 AS SELECT * FROM stock`,
+		`UPDATE _stock_old_20240518 SET place = 'sh' WHERE code = '000001'`,
+		// 行数比原表多：保留
+		`CREATE TABLE _stock_old_20240519 AS SELECT name, code FROM stock`,
+		`INSERT INTO _stock_old_20240519 (name, code) SELECT name, code FROM stock`,
+		// 重复的行比原表多，行数不比原表多，EXCEPT 没有差别：保留
+		`CREATE TABLE _stock_old_20240520 (name TEXT, code TEXT)`,
+		`INSERT INTO _stock_old_20240520 (name, code) VALUES ('平安银行', '000001'), ('平安银行', '000001')`,
+		// 有数据，target 不在原表中：保留
+		`CREATE TABLE _stock_target_old_20240520 (id INTEGER, target TEXT)`,
+		`INSERT INTO _stock_target_old_20240520 (id, target) VALUES (1, 'pe')`,
+		// 原表不存在：保留
+		`CREATE TABLE _quote_old_20240517 (code TEXT)`,
+		`INSERT INTO _quote_old_20240517 (code) VALUES ('000001')`,
+	}
+	for _, q := range setup {
+		if _, err := sqldb.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	if err := inTx(sqldb, func(tx *sql.Tx) error { return dropOldTables(tx, d) }); err != nil {
+		t.Fatal(err)
+	}
+
+	assertTables(t, sqldb, d, append([]string{
+		"_quote_old_20240517",
+		"_stock_old_20240518",
+		"_stock_old_20240519",
+		"_stock_old_20240520",
+		"_stock_target_old_20240520",
+	}, dataTables...))
+
+	out := buf.String()
+	for _, want := range []string{
+		"dropped _stock_data_old_20240517",
+		"dropped _stock_target_old_20240517",
+		"dropped _stock_old_20240517",
+		"keep _stock_old_20240518: 1 rows are not in stock",
+		"keep _stock_old_20240519: 4 rows, stock has only 2",
+		"keep _stock_old_20240520: 1 rows are not in stock",
+		"keep _stock_target_old_20240520: columns target are not in stock_target",
+		"keep _quote_old_20240517: 1 rows and table quote does not exist",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect 数据库类型。迁移和查询按 SQLite 的写法编写，Rewrite 转成对应数据库的写法
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+func (d Dialect) String() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// DriverName database/sql 的驱动名
+func (d Dialect) DriverName() string {
+	return d.String()
+}
+
+var (
+	autoIncrement = regexp.MustCompile(`(?i)\bINTEGER\s+PRIMARY\s+KEY\s+AUTOINCREMENT(\s+NOT\s+NULL)?(\s+DEFAULT\s+0)?`)
+	integerType   = regexp.MustCompile(`(?i)\bINTEGER\b`)
+	realType      = regexp.MustCompile(`(?i)\bREAL(\(\d+\s*,\s*\d+\))?`)
+	ifNull        = regexp.MustCompile(`(?i)\bIFNULL\(`)
+)
+
+// Rewrite 把 SQLite 写法的语句转成 d 的写法：
+// Postgres 中自增主键为 BIGSERIAL，INTEGER 为 BIGINT（成交量会超过 int32），REAL 为 DOUBLE PRECISION，
+// IFNULL 为 COALESCE，? 占位符为 $1、$2...
+func (d Dialect) Rewrite(query string) string {
+	if d != Postgres {
+		return query
+	}
+	query = autoIncrement.ReplaceAllString(query, "BIGSERIAL PRIMARY KEY")
+	query = integerType.ReplaceAllString(query, "BIGINT")
+	query = realType.ReplaceAllString(query, "DOUBLE PRECISION")
+	query = ifNull.ReplaceAllString(query, "COALESCE(")
+	return numberPlaceholders(query)
+}
+
+// numberPlaceholders 把字符串和标识符以外的 ? 换成 $n
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// tablesQuery 列出当前库中所有表名
+func (d Dialect) tablesQuery() string {
+	if d == Postgres {
+		return "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'"
+	}
+	return "SELECT name FROM sqlite_master WHERE type = 'table'"
+}
@@ -0,0 +1,224 @@
+// Package migrate 数据库的版本化迁移。
+// 迁移写在 migrations/NNNN_name.up.sql、NNNN_name.down.sql 中并嵌入程序，没有 down 文件的迁移不能回滚，
+// 需要判断的迁移用 Go 代码写在 goMigrations 中，已执行的版本记录在 schema_version 表。
+// 迁移按 SQLite 的写法编写，在 Postgres 中执行前由 Dialect.Rewrite 转换
+package migrate
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration 一个版本的迁移，Down 为 nil 时不能回滚
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx, d Dialect) error
+	Down    func(tx *sql.Tx, d Dialect) error
+}
+
+// State 迁移以及是否已执行
+type State struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+const createVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT    NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+// Migrations 所有迁移，按版本排序
+func Migrations() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+	for _, m := range goMigrations {
+		m := m
+		byVersion[m.Version] = &m
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		var suffix string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			suffix = ".up.sql"
+		case strings.HasSuffix(name, ".down.sql"):
+			suffix = ".down.sql"
+		default:
+			continue
+		}
+		up := suffix == ".up.sql"
+
+		base := strings.TrimSuffix(name, suffix)
+		num, title, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: file name must be NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+
+		bt, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		m, found := byVersion[version]
+		if !found {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration %d is defined twice: %s and %s", version, m.Name, title)
+		}
+		if up {
+			m.Up = execSQL(string(bt))
+		} else {
+			m.Down = execSQL(string(bt))
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d %s has no up", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func execSQL(query string) func(tx *sql.Tx, d Dialect) error {
+	return func(tx *sql.Tx, d Dialect) error {
+		_, err := tx.Exec(d.Rewrite(query))
+		return err
+	}
+}
+
+// applied 已执行的版本
+func applied(sqldb *sql.DB, d Dialect) (map[int]time.Time, error) {
+	if _, err := sqldb.Exec(d.Rewrite(createVersionTable)); err != nil {
+		return nil, err
+	}
+	rows, err := sqldb.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = time.Unix(at, 0)
+	}
+	return done, rows.Err()
+}
+
+// Status 每个迁移是否已执行
+func Status(sqldb *sql.DB, d Dialect) ([]State, error) {
+	list, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	done, err := applied(sqldb, d)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(list))
+	for _, m := range list {
+		at, ok := done[m.Version]
+		states = append(states, State{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return states, nil
+}
+
+// Up 按版本顺序执行所有未执行的迁移，每个迁移一个事务，返回执行了的迁移
+func Up(sqldb *sql.DB, d Dialect) ([]Migration, error) {
+	states, err := Status(sqldb, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range states {
+		if s.Applied {
+			continue
+		}
+		err := inTx(sqldb, func(tx *sql.Tx) error {
+			if err := s.Up(tx, d); err != nil {
+				return err
+			}
+			_, err := tx.Exec(d.Rewrite("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)"),
+				s.Version, s.Name, time.Now().Unix())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", s.Version, s.Name, err)
+		}
+		done = append(done, s.Migration)
+	}
+	return done, nil
+}
+
+// Down 从最新的版本开始回滚 steps 个已执行的迁移，返回回滚了的迁移
+func Down(sqldb *sql.DB, d Dialect, steps int) ([]Migration, error) {
+	states, err := Status(sqldb, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(states) - 1; i >= 0 && len(done) < steps; i-- {
+		s := states[i]
+		if !s.Applied {
+			continue
+		}
+		if s.Down == nil {
+			return done, fmt.Errorf("migration %d %s cannot be rolled back", s.Version, s.Name)
+		}
+		err := inTx(sqldb, func(tx *sql.Tx) error {
+			if err := s.Down(tx, d); err != nil {
+				return err
+			}
+			_, err := tx.Exec(d.Rewrite("DELETE FROM schema_version WHERE version = ?"), s.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", s.Version, s.Name, err)
+		}
+		done = append(done, s.Migration)
+	}
+	return done, nil
+}
+
+func inTx(sqldb *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
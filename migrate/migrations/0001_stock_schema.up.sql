@@ -0,0 +1,22 @@
+-- stock、stock_target、stock_data，和 db/stock2.db 中已有的表结构一致，已存在时跳过
+-- 没有 down：这些表在 schema_version 之前就已经存在，回滚不能删除其中的数据
+CREATE TABLE IF NOT EXISTS "stock" (
+"id"  INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+"name"  TEXT,
+"code"  TEXT,
+"place"  TEXT,
+"status"  INTEGER DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS "stock_target" (
+"id"  INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+"name"  TEXT
+);
+
+CREATE TABLE IF NOT EXISTS "stock_data" (
+"id"  INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL DEFAULT 0,
+"stock_id"  INTEGER DEFAULT 0,
+"period"  TEXT,
+"target_id"  INTEGER DEFAULT 0,
+"data"  REAL(7,2) DEFAULT 0
+);
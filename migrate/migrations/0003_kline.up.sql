@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS kline (
+	symbol      TEXT    NOT NULL,
+	period      TEXT    NOT NULL,
+	trading_day INTEGER NOT NULL,
+	time        INTEGER NOT NULL,
+	open        REAL    DEFAULT 0,
+	high        REAL    DEFAULT 0,
+	low         REAL    DEFAULT 0,
+	close       REAL    DEFAULT 0,
+	volume      INTEGER DEFAULT 0,
+	amount      REAL    DEFAULT 0,
+	pre_close   REAL    DEFAULT 0,
+	PRIMARY KEY (symbol, period, time)
+);
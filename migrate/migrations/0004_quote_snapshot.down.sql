@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_quote_snapshot_symbol_time;
+DROP TABLE IF EXISTS quote_snapshot;
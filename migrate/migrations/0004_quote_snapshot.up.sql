@@ -0,0 +1,16 @@
+-- time 为拉取的时间，quote_time 为数据源给出的行情时间
+CREATE TABLE IF NOT EXISTS quote_snapshot (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol     TEXT    NOT NULL,
+	time       INTEGER NOT NULL,
+	quote_time INTEGER DEFAULT 0,
+	pre_close  REAL    DEFAULT 0,
+	open       REAL    DEFAULT 0,
+	high       REAL    DEFAULT 0,
+	low        REAL    DEFAULT 0,
+	close      REAL    DEFAULT 0,
+	volume     INTEGER DEFAULT 0,
+	amount     REAL    DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_snapshot_symbol_time ON quote_snapshot (symbol, time);
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-colly/migrate"
+	"strings"
+)
+
+// copyTables 复制的表和列。
+// stock、stock_target 的 id 被其他表引用，复制 id，已存在的 id 跳过，postgres 需要重置序列；
+// stock_data、quote_snapshot 的 id 只是自增编号，不复制 id，按 key 判断 dst 中是否已有这一行
+var copyTables = []struct {
+	name    string
+	columns []string
+	key     []string
+	serial  bool
+}{
+	{"stock", []string{"id", "name", "code", "place", "status"}, nil, true},
+	{"stock_target", []string{"id", "name"}, nil, true},
+	{"stock_data", []string{"stock_id", "period", "target_id", "data"}, []string{"stock_id", "target_id", "period"}, false},
+	{"kline", []string{"symbol", "period", "trading_day", "time", "open", "high", "low", "close", "volume", "amount", "pre_close"}, nil, false},
+	{"quote_snapshot", []string{"symbol", "time", "quote_time", "pre_close", "open", "high", "low", "close", "volume", "amount"}, []string{"symbol", "time"}, false},
+}
+
+// CopyResult 一个表读取、写入和跳过的行数，dst 中已有的行跳过
+type CopyResult struct {
+	Table   string
+	Read    int64
+	Written int64
+	Skipped int64
+}
+
+// Copy 把 src 中所有表的数据复制到 dst，每个表一个事务。
+// 两边都需要已经迁移到最新版本，dst 中已有的行跳过，所以可以重复执行
+func Copy(ctx context.Context, dst, src *SQL) ([]CopyResult, error) {
+	results := make([]CopyResult, 0, len(copyTables))
+	for _, t := range copyTables {
+		r, err := copyTable(ctx, dst, src, t.name, t.columns, t.key, t.serial)
+		if err != nil {
+			return results, fmt.Errorf("copy %s: %w", t.name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func copyTable(ctx context.Context, dst, src *SQL, table string, columns, key []string, serial bool) (CopyResult, error) {
+	r := CopyResult{Table: table}
+	cols := strings.Join(columns, ", ")
+
+	rows, err := src.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", cols, table))
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	tx, err := dst.db.BeginTx(ctx, nil)
+	if err != nil {
+		return r, err
+	}
+	defer tx.Rollback()
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, cols, strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+	var existing map[string]int
+	keyIndex := make([]int, 0, len(key))
+	if key == nil {
+		insert += " ON CONFLICT DO NOTHING"
+	} else {
+		if existing, err = keyCounts(ctx, tx, table, key); err != nil {
+			return r, err
+		}
+		for _, k := range key {
+			for i, c := range columns {
+				if c == k {
+					keyIndex = append(keyIndex, i)
+				}
+			}
+		}
+	}
+	stmt, err := tx.PrepareContext(ctx, dst.dialect.Rewrite(insert))
+	if err != nil {
+		return r, err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return r, err
+		}
+		r.Read++
+		// sqlite 的 TEXT 可能扫描为 []byte，postgres 会当成 bytea
+		for i, v := range values {
+			if bt, ok := v.([]byte); ok {
+				values[i] = string(bt)
+			}
+		}
+		// dst 中 key 相同的行抵消 src 中同样多的行，src 中本来就重复的行也能复制过去
+		if existing != nil {
+			k := rowKey(values, keyIndex)
+			if existing[k] > 0 {
+				existing[k]--
+				r.Skipped++
+				continue
+			}
+		}
+		res, err := stmt.ExecContext(ctx, values...)
+		if err != nil {
+			return r, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return r, err
+		}
+		r.Written += n
+		r.Skipped += 1 - n
+	}
+	if err := rows.Err(); err != nil {
+		return r, err
+	}
+
+	// 写入了 id 之后 postgres 的序列不会自动前进
+	if serial && dst.dialect == migrate.Postgres {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM %s), false)", table, table)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return r, err
+		}
+	}
+	return r, tx.Commit()
+}
+
+// keyCounts dst 中每个 key 有几行
+func keyCounts(ctx context.Context, tx *sql.Tx, table string, key []string) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(key, ", "), table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	values := make([]interface{}, len(key))
+	dest := make([]interface{}, len(key))
+	index := make([]int, len(key))
+	for i := range values {
+		dest[i] = &values[i]
+		index[i] = i
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		counts[rowKey(values, index)]++
+	}
+	return counts, rows.Err()
+}
+
+// rowKey values 中 index 这几列拼成的字符串，NULL 和空字符串不同
+func rowKey(values []interface{}, index []int) string {
+	var b strings.Builder
+	for _, i := range index {
+		switch v := values[i].(type) {
+		case nil:
+			b.WriteString("\x01")
+		case []byte:
+			b.Write(v)
+		default:
+			fmt.Fprint(&b, v)
+		}
+		b.WriteString("\x00")
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"go-colly/util"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteCopy(t *testing.T) {
+	testCopy(t, openSQLite(t))
+}
+
+// openFixture 写入了 testdata/copy_fixture.sql 的 sqlite 库
+func openFixture(t *testing.T) *SQL {
+	t.Helper()
+	src := openSQLite(t)
+	fixture, err := os.ReadFile("testdata/copy_fixture.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.db.Exec(string(fixture)); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// testCopy 把 fixture 复制到迁移过的空库 dst，dst 中事先有和 fixture 相同的一条财报数据和一条报价
+func testCopy(t *testing.T, dst *SQL) {
+	ctx := context.Background()
+	src := openFixture(t)
+
+	if err := dst.InsertStockData(ctx, []DataPoint{{StockID: 1, TargetID: 1, Period: "2024Q1", Value: 100.5}}); err != nil {
+		t.Fatal(err)
+	}
+	err := dst.InsertSnapshots(ctx, time.Unix(1728527400, 0), []util.KlineData{
+		{Symbol: "sz000001", Time: 1728527398, PreClose: 11, Open: 11, High: 11.1, Low: 10.9, Close: 11.05, Volume: 300000000, Amount: 3300000000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Copy(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CopyResult{
+		{Table: "stock", Read: 3, Written: 3},
+		{Table: "stock_target", Read: 2, Written: 2},
+		{Table: "stock_data", Read: 6, Written: 5, Skipped: 1},
+		{Table: "kline", Read: 4, Written: 4},
+		{Table: "quote_snapshot", Read: 4, Written: 3, Skipped: 1},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("first Copy = %v, want %v", results, want)
+	}
+
+	// 再复制一次全部跳过
+	results, err = Copy(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		want[i].Skipped, want[i].Written = want[i].Read, 0
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("second Copy = %v, want %v", results, want)
+	}
+
+	for _, table := range []string{"stock", "stock_target", "stock_data", "kline", "quote_snapshot"} {
+		var got, want int
+		if err := src.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&want); err != nil {
+			t.Fatal(err)
+		}
+		if err := dst.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s has %d rows, want %d", table, got, want)
+		}
+	}
+
+	// 通过 Repository 读出来的数据和 src 相同
+	same := func(name string, read func(repo Repository) (interface{}, error)) {
+		t.Helper()
+		a, err := read(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := read(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: dst = %v, src = %v", name, b, a)
+		}
+	}
+	same("Stocks", func(repo Repository) (interface{}, error) { return repo.Stocks(ctx, 0) })
+	same("Targets", func(repo Repository) (interface{}, error) { return repo.Targets(ctx) })
+	for _, id := range []int{1, 2} {
+		same("StockData", func(repo Repository) (interface{}, error) { return repo.StockData(ctx, id) })
+	}
+	same("TradingDays", func(repo Repository) (interface{}, error) { return repo.TradingDays(ctx, "") })
+	for _, symbol := range []string{"sz000001", "sh600000"} {
+		same("LoadKlines", func(repo Repository) (interface{}, error) { return repo.LoadKlines(ctx, symbol, util.PeriodDay) })
+		same("LoadSnapshots", func(repo Repository) (interface{}, error) {
+			return repo.LoadSnapshots(ctx, symbol, time.Unix(0, 0), time.Unix(1<<32, 0))
+		})
+	}
+	same("LatestSnapshots", func(repo Repository) (interface{}, error) { return repo.LatestSnapshots(ctx) })
+
+	// 复制了 id 的表，之后不指定 id 写入不会和复制过来的 id 冲突
+	if _, err := dst.db.Exec(dst.dialect.Rewrite(`INSERT INTO stock (name, code, place, status) VALUES (?, ?, ?, ?)`), "新股", "001000", "sz", 1); err != nil {
+		t.Fatal(err)
+	}
+	stocks, err := dst.Stocks(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stocks); n != 3 || stocks[n-1].ID != 6 {
+		t.Errorf("Stocks after insert = %v, want the new stock with id 6", stocks)
+	}
+}
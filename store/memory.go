@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"go-colly/calendar"
+	"go-colly/util"
+	"sort"
+	"sync"
+	"time"
+)
+
+type klineKey struct {
+	symbol string
+	period util.Period
+}
+
+type snapshotRow struct {
+	at    int64
+	quote util.KlineData
+}
+
+// Memory 保存在内存中的 Repository，用于测试，数据不会持久化
+type Memory struct {
+	mu        sync.RWMutex
+	stocks    []Stock
+	targets   []Target
+	data      []DataPoint
+	klines    map[klineKey]map[int64]util.KlineData
+	snapshots []snapshotRow
+}
+
+func NewMemory() *Memory {
+	return &Memory{klines: make(map[klineKey]map[int64]util.KlineData)}
+}
+
+// AddStock 添加股票，ID 为 0 时自动分配
+func (m *Memory) AddStock(v Stock) Stock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v.ID == 0 {
+		for _, o := range m.stocks {
+			if o.ID >= v.ID {
+				v.ID = o.ID + 1
+			}
+		}
+		if v.ID == 0 {
+			v.ID = 1
+		}
+	}
+	m.stocks = append(m.stocks, v)
+	sort.Slice(m.stocks, func(i, j int) bool { return m.stocks[i].ID < m.stocks[j].ID })
+	return v
+}
+
+// AddTarget 添加指标，ID 为 0 时自动分配
+func (m *Memory) AddTarget(v Target) Target {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v.ID == 0 {
+		for _, o := range m.targets {
+			if o.ID >= v.ID {
+				v.ID = o.ID + 1
+			}
+		}
+		if v.ID == 0 {
+			v.ID = 1
+		}
+	}
+	m.targets = append(m.targets, v)
+	sort.Slice(m.targets, func(i, j int) bool { return m.targets[i].ID < m.targets[j].ID })
+	return v
+}
+
+func (m *Memory) Close() error { return nil }
+
+func (m *Memory) Stocks(ctx context.Context, limit int) ([]Stock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Stock, 0)
+	for _, v := range m.stocks {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+		if v.Status == 1 {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) Targets(ctx context.Context) ([]Target, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Target{}, m.targets...), nil
+}
+
+func (m *Memory) StockData(ctx context.Context, stockID int) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stock *Stock
+	for i := range m.stocks {
+		if m.stocks[i].ID == stockID {
+			stock = &m.stocks[i]
+		}
+	}
+	if stock == nil {
+		return []DataPoint{}, nil
+	}
+	targets := make(map[int]string, len(m.targets))
+	for _, t := range m.targets {
+		targets[t.ID] = t.Name
+	}
+
+	// 和 sqlite 一样只返回指标存在的数据
+	list := make([]DataPoint, 0)
+	for _, v := range m.data {
+		name, ok := targets[v.TargetID]
+		if v.StockID != stockID || !ok {
+			continue
+		}
+		v.StockName = stock.Name
+		v.TargetName = name
+		list = append(list, v)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].TargetID < list[j].TargetID })
+	return list, nil
+}
+
+func (m *Memory) Periods(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	list := make([]string, 0)
+	for _, v := range m.data {
+		if !seen[v.Period] {
+			seen[v.Period] = true
+			list = append(list, v.Period)
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) InsertStockData(ctx context.Context, points []DataPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range points {
+		m.data = append(m.data, DataPoint{StockID: v.StockID, TargetID: v.TargetID, Period: v.Period, Value: v.Value})
+	}
+	return nil
+}
+
+func (m *Memory) LatestKlineTime(ctx context.Context, symbol string, period util.Period) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest int64
+	for t := range m.klines[klineKey{symbol, period}] {
+		if t > latest {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+func (m *Memory) CountKlines(ctx context.Context, symbol string, period util.Period, from, to int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for t := range m.klines[klineKey{symbol, period}] {
+		if t >= from && t <= to {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) UpsertKlines(ctx context.Context, symbol string, period util.Period, bars []util.KlineData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := klineKey{symbol, period}
+	if m.klines[k] == nil {
+		m.klines[k] = make(map[int64]util.KlineData)
+	}
+	for _, v := range bars {
+		v.Symbol = symbol
+		m.klines[k][v.Time] = v
+	}
+	return nil
+}
+
+func (m *Memory) LoadKlines(ctx context.Context, symbol string, period util.Period) ([]util.KlineData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]util.KlineData, 0, len(m.klines[klineKey{symbol, period}]))
+	for _, v := range m.klines[klineKey{symbol, period}] {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time < list[j].Time })
+	return list, nil
+}
+
+func (m *Memory) TradingDays(ctx context.Context, symbol string) ([]time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[int64]bool)
+	for k, bars := range m.klines {
+		if k.period != util.PeriodDay || (symbol != "" && k.symbol != symbol) {
+			continue
+		}
+		for _, v := range bars {
+			seen[v.TradingDay] = true
+		}
+	}
+	secs := make([]int64, 0, len(seen))
+	for sec := range seen {
+		secs = append(secs, sec)
+	}
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+
+	list := make([]time.Time, 0, len(secs))
+	for _, sec := range secs {
+		list = append(list, calendar.FromUnix(sec))
+	}
+	return list, nil
+}
+
+func (m *Memory) LatestSnapshots(ctx context.Context) (map[string]util.KlineData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := make(map[string]util.KlineData)
+	for _, row := range m.snapshots {
+		latest[row.quote.Symbol] = row.quote
+	}
+	return latest, nil
+}
+
+func (m *Memory) InsertSnapshots(ctx context.Context, at time.Time, quotes []util.KlineData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range quotes {
+		if v.Time == 0 {
+			v.Time = at.Unix()
+		}
+		m.snapshots = append(m.snapshots, snapshotRow{at: at.Unix(), quote: v})
+	}
+	return nil
+}
+
+func (m *Memory) LoadSnapshots(ctx context.Context, symbol string, from, to time.Time) ([]util.KlineData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rows := make([]snapshotRow, 0)
+	for _, row := range m.snapshots {
+		if row.quote.Symbol == symbol && row.at >= from.Unix() && row.at <= to.Unix() {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].at < rows[j].at })
+
+	list := make([]util.KlineData, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, row.quote)
+	}
+	return list, nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-colly/migrate"
+	"go-colly/util"
+	"log"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// ParseDSN postgres:// 或 postgresql:// 开头的为 postgres，其他的为 sqlite 文件路径
+func ParseDSN(dsn string) migrate.Dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return migrate.Postgres
+	}
+	return migrate.SQLite
+}
+
+// Connect 只连接数据库，不执行迁移
+func Connect(dsn string) (*sql.DB, migrate.Dialect, error) {
+	if dsn == "" {
+		return nil, 0, errors.New("empty database dsn")
+	}
+	dialect := ParseDSN(dsn)
+	if dialect == migrate.SQLite {
+		if err := util.CheckAndMakeDirAll(filepath.Dir(dsn)); err != nil {
+			return nil, dialect, err
+		}
+		sqldb, err := util.OpenSqlite3(dsn)
+		return sqldb, dialect, err
+	}
+
+	sqldb, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		return nil, dialect, errors.New("open database failed: " + err.Error())
+	}
+	if err := sqldb.Ping(); err != nil {
+		sqldb.Close()
+		return nil, dialect, errors.New("connect database failed: " + err.Error())
+	}
+	fmt.Println("connect to ", redact(dsn), "ok")
+	return sqldb, dialect, nil
+}
+
+// OpenDSN 连接数据库并执行未执行的迁移
+func OpenDSN(dsn string) (*SQL, error) {
+	sqldb, dialect, err := Connect(dsn)
+	if err != nil {
+		return nil, err
+	}
+	done, err := migrate.Up(sqldb, dialect)
+	for _, m := range done {
+		log.Printf("migrated %s to version %d %s", redact(dsn), m.Version, m.Name)
+	}
+	if err != nil {
+		sqldb.Close()
+		return nil, err
+	}
+
+	s, err := New(context.Background(), sqldb, dialect)
+	if err != nil {
+		sqldb.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open 打开配置中的数据库：设置了 database 时使用它，否则为 db_path 的 sqlite
+func Open() (*SQL, error) {
+	return OpenDSN(ConfiguredDSN())
+}
+
+// ConfiguredDSN 配置中的数据库
+func ConfiguredDSN() string {
+	s := util.ActiveSettings()
+	if s.Database != "" {
+		return s.Database
+	}
+	return s.DBPath
+}
+
+// redact 打印时隐藏 postgres 的密码
+func redact(dsn string) string {
+	if ParseDSN(dsn) == migrate.SQLite {
+		return dsn
+	}
+	scheme, rest, _ := strings.Cut(dsn, "://")
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return dsn
+	}
+	if user, _, ok := strings.Cut(rest[:at], ":"); ok {
+		return scheme + "://" + user + ":***" + rest[at:]
+	}
+	return dsn
+}
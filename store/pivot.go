@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReportPeriod 财报期，Quarter 为 1~4 时为季报，为 0 时为年报，如 2024Q1、2024
+type ReportPeriod struct {
+	Year    int
+	Quarter int
+}
+
+// ParseReportPeriod 解析 stock_data.period
+func ParseReportPeriod(s string) (ReportPeriod, error) {
+	year, quarter, hasQuarter := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), "Q")
+	var p ReportPeriod
+	var err error
+	if p.Year, err = strconv.Atoi(year); err != nil || p.Year < 1900 || p.Year > 9999 {
+		return p, fmt.Errorf("invalid report period %q, want 2024 or 2024Q1", s)
+	}
+	if hasQuarter {
+		if p.Quarter, err = strconv.Atoi(quarter); err != nil || p.Quarter < 1 || p.Quarter > 4 {
+			return p, fmt.Errorf("invalid report period %q, want 2024 or 2024Q1", s)
+		}
+	}
+	return p, nil
+}
+
+func (p ReportPeriod) String() string {
+	if p.Quarter == 0 {
+		return strconv.Itoa(p.Year)
+	}
+	return fmt.Sprintf("%dQ%d", p.Year, p.Quarter)
+}
+
+// key 排序用，同一年中 Q1 < Q2 < Q3 < Q4 < 年报
+func (p ReportPeriod) key() int {
+	q := p.Quarter
+	if q == 0 {
+		q = 5
+	}
+	return p.Year*10 + q
+}
+
+// PeriodRange 选择的财报期范围和顺序
+type PeriodRange struct {
+	from, to int // key，0 为不限制
+	Desc     bool
+}
+
+// NewPeriodRange from、to 为空时不限制。只写年份时 from 从该年 Q1 开始，to 到该年年报为止
+func NewPeriodRange(from, to string, desc bool) (PeriodRange, error) {
+	r := PeriodRange{Desc: desc}
+	if from != "" {
+		p, err := ParseReportPeriod(from)
+		if err != nil {
+			return r, err
+		}
+		r.from = p.key()
+		if p.Quarter == 0 {
+			r.from = p.Year*10 + 1
+		}
+	}
+	if to != "" {
+		p, err := ParseReportPeriod(to)
+		if err != nil {
+			return r, err
+		}
+		r.to = p.key()
+	}
+	if r.from != 0 && r.to != 0 && r.from > r.to {
+		return r, fmt.Errorf("report period range %s..%s is empty", from, to)
+	}
+	return r, nil
+}
+
+// Contains p 是否在范围内
+func (r PeriodRange) Contains(p ReportPeriod) bool {
+	k := p.key()
+	return (r.from == 0 || k >= r.from) && (r.to == 0 || k <= r.to)
+}
+
+// Sort 按 r 的顺序排序
+func (r PeriodRange) Sort(periods []ReportPeriod) {
+	sort.Slice(periods, func(i, j int) bool {
+		if r.Desc {
+			return periods[i].key() > periods[j].key()
+		}
+		return periods[i].key() < periods[j].key()
+	})
+}
+
+// PivotRow 一只股票的一个指标在各个财报期的数据
+type PivotRow struct {
+	StockID    int
+	StockName  string
+	TargetID   int
+	TargetName string
+	Values     map[ReportPeriod]float64
+}
+
+// Pivot 财报数据透视表：行为 (股票, 指标)，列为 stock_data 中出现过的财报期
+type Pivot struct {
+	Periods []ReportPeriod
+	Rows    []PivotRow
+	// Skipped 无法解析的 stock_data.period，这些数据不在表中
+	Skipped []string
+}
+
+// LoadPivot 读取 stocks 的财报数据，列为范围 r 内的财报期，行按股票、指标 id 排序，没有数据的指标不列出
+func LoadPivot(ctx context.Context, repo Repository, stocks []Stock, r PeriodRange) (*Pivot, error) {
+	names, err := repo.Periods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pivot := &Pivot{}
+	for _, name := range names {
+		p, err := ParseReportPeriod(name)
+		if err != nil {
+			pivot.Skipped = append(pivot.Skipped, name)
+			continue
+		}
+		if r.Contains(p) {
+			pivot.Periods = append(pivot.Periods, p)
+		}
+	}
+	r.Sort(pivot.Periods)
+	sort.Strings(pivot.Skipped)
+
+	for _, stock := range stocks {
+		points, err := repo.StockData(ctx, stock.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// StockData 按指标 id 排序，同一个指标的数据是连续的
+		for _, v := range points {
+			p, err := ParseReportPeriod(v.Period)
+			if err != nil || !r.Contains(p) {
+				continue
+			}
+			n := len(pivot.Rows)
+			if n == 0 || pivot.Rows[n-1].StockID != v.StockID || pivot.Rows[n-1].TargetID != v.TargetID {
+				pivot.Rows = append(pivot.Rows, PivotRow{
+					StockID:    v.StockID,
+					StockName:  v.StockName,
+					TargetID:   v.TargetID,
+					TargetName: v.TargetName,
+					Values:     make(map[ReportPeriod]float64),
+				})
+				n++
+			}
+			pivot.Rows[n-1].Values[p] = v.Value
+		}
+	}
+	return pivot, nil
+}
+
+// Table 表头和每一行的值：ID、名称、指标，然后是每个财报期，没有数据的为 nil
+func (p *Pivot) Table() ([]string, [][]interface{}) {
+	headers := []string{"ID", "名称", "指标"}
+	for _, period := range p.Periods {
+		headers = append(headers, period.String())
+	}
+
+	rows := make([][]interface{}, 0, len(p.Rows))
+	for _, r := range p.Rows {
+		row := []interface{}{r.StockID, r.StockName, r.TargetName}
+		for _, period := range p.Periods {
+			if v, ok := r.Values[period]; ok {
+				row = append(row, v)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"go-colly/calendar"
+	"go-colly/util"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// repoFactory 创建一个空的 Repository，seed 写入股票和指标（Repository 没有写这两张表的方法）
+type repoFactory func(t *testing.T) (repo Repository, seed func(stocks []Stock, targets []Target))
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) (Repository, func([]Stock, []Target)) {
+		m := NewMemory()
+		return m, func(stocks []Stock, targets []Target) {
+			for _, v := range stocks {
+				m.AddStock(v)
+			}
+			for _, v := range targets {
+				m.AddTarget(v)
+			}
+		}
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) (Repository, func([]Stock, []Target)) {
+		s := openSQLite(t)
+		return s, func(stocks []Stock, targets []Target) { seedSQL(t, s, stocks, targets) }
+	})
+}
+
+// openSQLite 临时目录中迁移过的空 sqlite 库，测试结束时关闭
+func openSQLite(t *testing.T) *SQL {
+	t.Helper()
+	s, err := OpenDSN(filepath.Join(t.TempDir(), "stock.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// seedSQL 按 id 写入股票和指标
+func seedSQL(t *testing.T, s *SQL, stocks []Stock, targets []Target) {
+	t.Helper()
+	for _, v := range stocks {
+		_, err := s.db.Exec(s.dialect.Rewrite(`INSERT INTO stock (id, name, code, place, status) VALUES (?, ?, ?, ?, ?)`),
+			v.ID, v.Name, v.Code, v.Place, v.Status)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range targets {
+		if _, err := s.db.Exec(s.dialect.Rewrite(`INSERT INTO stock_target (id, name) VALUES (?, ?)`), v.ID, v.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testRepository 每个实现都要通过的测试，每个子测试使用新的 Repository
+func testRepository(t *testing.T, newRepo repoFactory) {
+	ctx := context.Background()
+
+	t.Run("Stocks", func(t *testing.T) {
+		repo, seed := newRepo(t)
+		seed([]Stock{
+			{ID: 4, Name: "d", Code: "000004", Place: "sz", Status: 1},
+			{ID: 1, Name: "a", Code: "600001", Place: "sh", Status: 1},
+			{ID: 2, Name: "b", Code: "600002", Place: "sh", Status: 0},
+			{ID: 3, Name: "c", Code: "000003", Place: "sz", Status: 1},
+		}, []Target{{ID: 2, Name: "营收"}, {ID: 1, Name: "净利润"}})
+
+		all, err := repo.Stocks(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Stock{
+			{ID: 1, Name: "a", Code: "600001", Place: "sh", Status: 1},
+			{ID: 3, Name: "c", Code: "000003", Place: "sz", Status: 1},
+			{ID: 4, Name: "d", Code: "000004", Place: "sz", Status: 1},
+		}
+		if !reflect.DeepEqual(all, want) {
+			t.Errorf("Stocks(0) = %v, want %v", all, want)
+		}
+
+		limited, err := repo.Stocks(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(limited, want[:2]) {
+			t.Errorf("Stocks(2) = %v, want %v", limited, want[:2])
+		}
+
+		targets, err := repo.Targets(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantTargets := []Target{{ID: 1, Name: "净利润"}, {ID: 2, Name: "营收"}}; !reflect.DeepEqual(targets, wantTargets) {
+			t.Errorf("Targets = %v, want %v", targets, wantTargets)
+		}
+	})
+
+	t.Run("StockData", func(t *testing.T) {
+		repo, seed := newRepo(t)
+		seed([]Stock{{ID: 1, Name: "a", Status: 1}, {ID: 2, Name: "b", Status: 1}},
+			[]Target{{ID: 1, Name: "净利润"}, {ID: 2, Name: "营收"}})
+
+		err := repo.InsertStockData(ctx, []DataPoint{
+			{StockID: 1, TargetID: 2, Period: "2024Q1", Value: 1.5},
+			{StockID: 1, TargetID: 1, Period: "2024Q1", Value: 2.5},
+			{StockID: 1, TargetID: 2, Period: "2024", Value: 3.5},
+			{StockID: 1, TargetID: 1, Period: "2024", Value: 4.5},
+			{StockID: 1, TargetID: 9, Period: "2024", Value: 5.5}, // 指标不存在
+			{StockID: 2, TargetID: 1, Period: "2023Q4", Value: 6.5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 按指标 id 排序，同一个指标按写入的顺序
+		points, err := repo.StockData(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []DataPoint{
+			{StockID: 1, StockName: "a", TargetID: 1, TargetName: "净利润", Period: "2024Q1", Value: 2.5},
+			{StockID: 1, StockName: "a", TargetID: 1, TargetName: "净利润", Period: "2024", Value: 4.5},
+			{StockID: 1, StockName: "a", TargetID: 2, TargetName: "营收", Period: "2024Q1", Value: 1.5},
+			{StockID: 1, StockName: "a", TargetID: 2, TargetName: "营收", Period: "2024", Value: 3.5},
+		}
+		if !reflect.DeepEqual(points, want) {
+			t.Errorf("StockData(1) = %v, want %v", points, want)
+		}
+
+		if points, err := repo.StockData(ctx, 3); err != nil || len(points) != 0 {
+			t.Errorf("StockData(3) = %v, %v, want no data", points, err)
+		}
+
+		periods, err := repo.Periods(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := make(map[string]bool)
+		for _, p := range periods {
+			set[p] = true
+		}
+		if wantSet := map[string]bool{"2024Q1": true, "2024": true, "2023Q4": true}; len(periods) != 3 || !reflect.DeepEqual(set, wantSet) {
+			t.Errorf("Periods = %v, want 2024Q1, 2024, 2023Q4", periods)
+		}
+	})
+
+	t.Run("Klines", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		if latest, err := repo.LatestKlineTime(ctx, "sz000001", util.PeriodDay); err != nil || latest != 0 {
+			t.Errorf("LatestKlineTime on empty = %d, %v, want 0", latest, err)
+		}
+
+		err := repo.UpsertKlines(ctx, "sz000001", util.PeriodDay, []util.KlineData{
+			{TradingDay: 100, Time: 100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10, Amount: 15, PreClose: 1},
+			{TradingDay: 200, Time: 200, Open: 1.5, High: 2, Low: 1, Close: 1.8, Volume: 20, Amount: 36, PreClose: 1.5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest, err := repo.LatestKlineTime(ctx, "sz000001", util.PeriodDay); err != nil || latest != 200 {
+			t.Errorf("LatestKlineTime = %d, %v, want 200", latest, err)
+		}
+
+		// 同一时间的 K 线覆盖原来的值
+		err = repo.UpsertKlines(ctx, "sz000001", util.PeriodDay, []util.KlineData{
+			{TradingDay: 200, Time: 200, Open: 1.5, High: 2.2, Low: 1, Close: 2.1, Volume: 30, Amount: 60, PreClose: 1.5},
+			{TradingDay: 300, Time: 300, Open: 2.1, High: 2.3, Low: 2, Close: 2.2, Volume: 5, Amount: 11, PreClose: 2.1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest, err := repo.LatestKlineTime(ctx, "sz000001", util.PeriodDay); err != nil || latest != 300 {
+			t.Errorf("LatestKlineTime after upsert = %d, %v, want 300", latest, err)
+		}
+		if latest, err := repo.LatestKlineTime(ctx, "sz000001", util.PeriodMin1); err != nil || latest != 0 {
+			t.Errorf("LatestKlineTime of another period = %d, %v, want 0", latest, err)
+		}
+		for _, tt := range []struct {
+			from, to int64
+			want     int
+		}{{0, 1000, 3}, {100, 200, 2}, {150, 250, 1}, {400, 500, 0}} {
+			if n, err := repo.CountKlines(ctx, "sz000001", util.PeriodDay, tt.from, tt.to); err != nil || n != tt.want {
+				t.Errorf("CountKlines(%d, %d) = %d, %v, want %d", tt.from, tt.to, n, err, tt.want)
+			}
+		}
+
+		bars, err := repo.LoadKlines(ctx, "sz000001", util.PeriodDay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []util.KlineData{
+			{Symbol: "sz000001", TradingDay: 100, Time: 100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10, Amount: 15, PreClose: 1},
+			{Symbol: "sz000001", TradingDay: 200, Time: 200, Open: 1.5, High: 2.2, Low: 1, Close: 2.1, Volume: 30, Amount: 60, PreClose: 1.5},
+			{Symbol: "sz000001", TradingDay: 300, Time: 300, Open: 2.1, High: 2.3, Low: 2, Close: 2.2, Volume: 5, Amount: 11, PreClose: 2.1},
+		}
+		if !reflect.DeepEqual(bars, want) {
+			t.Errorf("LoadKlines = %v, want %v", bars, want)
+		}
+	})
+
+	t.Run("TradingDays", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		day := func(s string) int64 {
+			d, err := time.ParseInLocation("2006-01-02", s, calendar.Location())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return d.Unix()
+		}
+		upsert := func(symbol string, period util.Period, days ...string) {
+			var bars []util.KlineData
+			for _, d := range days {
+				bars = append(bars, util.KlineData{TradingDay: day(d), Time: day(d) + 15*3600})
+			}
+			if err := repo.UpsertKlines(ctx, symbol, period, bars); err != nil {
+				t.Fatal(err)
+			}
+		}
+		upsert("sz000001", util.PeriodDay, "2024-10-09", "2024-10-08")
+		upsert("sh600000", util.PeriodDay, "2024-10-08", "2024-10-10")
+		upsert("sz000001", util.PeriodMin1, "2024-10-11") // 只算日 K
+
+		format := func(days []time.Time) []string {
+			list := make([]string, 0, len(days))
+			for _, d := range days {
+				list = append(list, d.Format("2006-01-02"))
+			}
+			return list
+		}
+
+		all, err := repo.TradingDays(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := format(all), []string{"2024-10-08", "2024-10-09", "2024-10-10"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("TradingDays(\"\") = %v, want %v", got, want)
+		}
+
+		one, err := repo.TradingDays(ctx, "sz000001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := format(one), []string{"2024-10-08", "2024-10-09"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("TradingDays(sz000001) = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Snapshots", func(t *testing.T) {
+		repo, _ := newRepo(t)
+
+		t1 := time.Unix(1728527400, 0)
+		t2 := t1.Add(5 * time.Second)
+		err := repo.InsertSnapshots(ctx, t1, []util.KlineData{
+			{Symbol: "sz000001", Time: 1728527398, PreClose: 10, Open: 10.1, High: 10.5, Low: 9.9, Close: 10.2, Volume: 100, Amount: 1020},
+			{Symbol: "sh600000", Time: 1728527399, PreClose: 8, Open: 8, High: 8.2, Low: 7.9, Close: 8.1, Volume: 50, Amount: 405},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 没有报价时间时用保存的时间
+		err = repo.InsertSnapshots(ctx, t2, []util.KlineData{
+			{Symbol: "sz000001", PreClose: 10, Open: 10.1, High: 10.6, Low: 9.9, Close: 10.4, Volume: 120, Amount: 1250},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		latest, err := repo.LatestSnapshots(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantLatest := map[string]util.KlineData{
+			"sz000001": {Symbol: "sz000001", Time: t2.Unix(), PreClose: 10, Open: 10.1, High: 10.6, Low: 9.9, Close: 10.4, Volume: 120, Amount: 1250},
+			"sh600000": {Symbol: "sh600000", Time: 1728527399, PreClose: 8, Open: 8, High: 8.2, Low: 7.9, Close: 8.1, Volume: 50, Amount: 405},
+		}
+		if !reflect.DeepEqual(latest, wantLatest) {
+			t.Errorf("LatestSnapshots = %v, want %v", latest, wantLatest)
+		}
+
+		list, err := repo.LoadSnapshots(ctx, "sz000001", t1, t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []util.KlineData{
+			{Symbol: "sz000001", Time: 1728527398, PreClose: 10, Open: 10.1, High: 10.5, Low: 9.9, Close: 10.2, Volume: 100, Amount: 1020},
+			wantLatest["sz000001"],
+		}
+		if !reflect.DeepEqual(list, want) {
+			t.Errorf("LoadSnapshots = %v, want %v", list, want)
+		}
+
+		// from、to 都包括，按保存的时间过滤
+		list, err = repo.LoadSnapshots(ctx, "sz000001", t1.Add(time.Second), t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(list, want[1:]) {
+			t.Errorf("LoadSnapshots after t1 = %v, want %v", list, want[1:])
+		}
+	})
+}
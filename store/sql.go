@@ -0,0 +1,330 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-colly/calendar"
+	"go-colly/migrate"
+	"go-colly/util"
+	"time"
+)
+
+// queries 按 SQLite 的写法编写，打开时按数据库的 Dialect 转换后全部预编译
+var queries = map[string]string{
+	"stocks": `
+	SELECT id, IFNULL(name, ''), IFNULL(code, ''), IFNULL(place, ''), IFNULL(status, 0)
+	FROM stock
+	WHERE status = 1
+	ORDER BY id ASC`,
+	"targets": `SELECT id, IFNULL(name, '') FROM stock_target ORDER BY id ASC`,
+	"stockData": `
+	SELECT b.id, IFNULL(b.name, ''), c.id, IFNULL(c.name, ''), IFNULL(a.period, ''), IFNULL(a.data, 0)
+	FROM stock_data a
+	INNER JOIN stock b ON a.stock_id = b.id
+	INNER JOIN stock_target c ON a.target_id = c.id
+	WHERE a.stock_id = ?
+	ORDER BY a.target_id ASC, a.id ASC`,
+	"periods":         `SELECT DISTINCT period FROM stock_data WHERE period IS NOT NULL`,
+	"insertStockData": `INSERT INTO stock_data (stock_id, period, target_id, data) VALUES (?, ?, ?, ?)`,
+	"latestKline":     `SELECT MAX(time) FROM kline WHERE symbol = ? AND period = ?`,
+	"countKlines":     `SELECT COUNT(*) FROM kline WHERE symbol = ? AND period = ? AND time BETWEEN ? AND ?`,
+	"upsertKline": `
+	INSERT INTO kline (symbol, period, trading_day, time, open, high, low, close, volume, amount, pre_close)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (symbol, period, time) DO UPDATE SET
+		trading_day = excluded.trading_day,
+		open = excluded.open,
+		high = excluded.high,
+		low = excluded.low,
+		close = excluded.close,
+		volume = excluded.volume,
+		amount = excluded.amount,
+		pre_close = excluded.pre_close`,
+	"loadKlines": `
+	SELECT trading_day, time, open, high, low, close, volume, amount, pre_close
+	FROM kline
+	WHERE symbol = ? AND period = ?
+	ORDER BY time ASC`,
+	"tradingDays": `
+	SELECT DISTINCT trading_day FROM kline
+	WHERE period = 'DAY' AND (? = '' OR symbol = ?)
+	ORDER BY trading_day ASC`,
+	"latestSnapshots": `
+	SELECT s.symbol, s.time, s.quote_time, s.pre_close, s.open, s.high, s.low, s.close, s.volume, s.amount
+	FROM quote_snapshot s
+	JOIN (SELECT symbol, MAX(id) AS id FROM quote_snapshot GROUP BY symbol) m ON s.id = m.id`,
+	"insertSnapshot": `
+	INSERT INTO quote_snapshot (symbol, time, quote_time, pre_close, open, high, low, close, volume, amount)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	"loadSnapshots": `
+	SELECT time, quote_time, pre_close, open, high, low, close, volume, amount
+	FROM quote_snapshot
+	WHERE symbol = ? AND time >= ? AND time <= ?
+	ORDER BY time ASC`,
+}
+
+// SQL 保存在 sqlite 或 postgres 数据库中的 Repository
+type SQL struct {
+	db      *sql.DB
+	dialect migrate.Dialect
+	stmts   map[string]*sql.Stmt
+}
+
+// New 使用已经迁移过的数据库，Close 时一起关闭
+func New(ctx context.Context, sqldb *sql.DB, dialect migrate.Dialect) (*SQL, error) {
+	s := &SQL{db: sqldb, dialect: dialect, stmts: make(map[string]*sql.Stmt, len(queries))}
+	for name, query := range queries {
+		stmt, err := sqldb.PrepareContext(ctx, dialect.Rewrite(query))
+		if err != nil {
+			s.closeStmts()
+			return nil, fmt.Errorf("prepare %s: %w", name, err)
+		}
+		s.stmts[name] = stmt
+	}
+	return s, nil
+}
+
+// Dialect 数据库类型
+func (s *SQL) Dialect() migrate.Dialect {
+	return s.dialect
+}
+
+func (s *SQL) closeStmts() {
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+}
+
+func (s *SQL) Close() error {
+	s.closeStmts()
+	return s.db.Close()
+}
+
+func (s *SQL) Stocks(ctx context.Context, limit int) ([]Stock, error) {
+	rows, err := s.stmts["stocks"].QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Stock, 0)
+	for rows.Next() && (limit <= 0 || len(list) < limit) {
+		var v Stock
+		if err := rows.Scan(&v.ID, &v.Name, &v.Code, &v.Place, &v.Status); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) Targets(ctx context.Context) ([]Target, error) {
+	rows, err := s.stmts["targets"].QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Target, 0)
+	for rows.Next() {
+		var v Target
+		if err := rows.Scan(&v.ID, &v.Name); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) StockData(ctx context.Context, stockID int) ([]DataPoint, error) {
+	rows, err := s.stmts["stockData"].QueryContext(ctx, stockID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]DataPoint, 0)
+	for rows.Next() {
+		var v DataPoint
+		if err := rows.Scan(&v.StockID, &v.StockName, &v.TargetID, &v.TargetName, &v.Period, &v.Value); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) Periods(ctx context.Context) ([]string, error) {
+	rows, err := s.stmts["periods"].QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]string, 0)
+	for rows.Next() {
+		var period string
+		if err := rows.Scan(&period); err != nil {
+			return nil, err
+		}
+		list = append(list, period)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) InsertStockData(ctx context.Context, points []DataPoint) error {
+	return s.inTx(ctx, "insertStockData", func(stmt *sql.Stmt) error {
+		for _, v := range points {
+			if _, err := stmt.ExecContext(ctx, v.StockID, v.Period, v.TargetID, v.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQL) LatestKlineTime(ctx context.Context, symbol string, period util.Period) (int64, error) {
+	var t sql.NullInt64
+	if err := s.stmts["latestKline"].QueryRowContext(ctx, symbol, period).Scan(&t); err != nil {
+		return 0, err
+	}
+	return t.Int64, nil
+}
+
+func (s *SQL) CountKlines(ctx context.Context, symbol string, period util.Period, from, to int64) (int, error) {
+	var n int
+	if err := s.stmts["countKlines"].QueryRowContext(ctx, symbol, period, from, to).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *SQL) UpsertKlines(ctx context.Context, symbol string, period util.Period, bars []util.KlineData) error {
+	if len(bars) == 0 {
+		return nil
+	}
+	return s.inTx(ctx, "upsertKline", func(stmt *sql.Stmt) error {
+		for _, v := range bars {
+			_, err := stmt.ExecContext(ctx, symbol, period, v.TradingDay, v.Time, v.Open, v.High, v.Low, v.Close, v.Volume, v.Amount, v.PreClose)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQL) LoadKlines(ctx context.Context, symbol string, period util.Period) ([]util.KlineData, error) {
+	rows, err := s.stmts["loadKlines"].QueryContext(ctx, symbol, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]util.KlineData, 0)
+	for rows.Next() {
+		var v util.KlineData
+		err := rows.Scan(&v.TradingDay, &v.Time, &v.Open, &v.High, &v.Low, &v.Close, &v.Volume, &v.Amount, &v.PreClose)
+		if err != nil {
+			return nil, err
+		}
+		v.Symbol = symbol
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) TradingDays(ctx context.Context, symbol string) ([]time.Time, error) {
+	rows, err := s.stmts["tradingDays"].QueryContext(ctx, symbol, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]time.Time, 0)
+	for rows.Next() {
+		var sec int64
+		if err := rows.Scan(&sec); err != nil {
+			return nil, err
+		}
+		list = append(list, calendar.FromUnix(sec))
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) LatestSnapshots(ctx context.Context) (map[string]util.KlineData, error) {
+	rows, err := s.stmts["latestSnapshots"].QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]util.KlineData)
+	for rows.Next() {
+		var v util.KlineData
+		var at int64
+		err := rows.Scan(&v.Symbol, &at, &v.Time, &v.PreClose, &v.Open, &v.High, &v.Low, &v.Close, &v.Volume, &v.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if v.Time == 0 {
+			v.Time = at
+		}
+		latest[v.Symbol] = v
+	}
+	return latest, rows.Err()
+}
+
+func (s *SQL) InsertSnapshots(ctx context.Context, at time.Time, quotes []util.KlineData) error {
+	return s.inTx(ctx, "insertSnapshot", func(stmt *sql.Stmt) error {
+		for _, v := range quotes {
+			_, err := stmt.ExecContext(ctx, v.Symbol, at.Unix(), v.Time, v.PreClose, v.Open, v.High, v.Low, v.Close, v.Volume, v.Amount)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQL) LoadSnapshots(ctx context.Context, symbol string, from, to time.Time) ([]util.KlineData, error) {
+	rows, err := s.stmts["loadSnapshots"].QueryContext(ctx, symbol, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]util.KlineData, 0)
+	for rows.Next() {
+		var v util.KlineData
+		var at int64
+		err := rows.Scan(&at, &v.Time, &v.PreClose, &v.Open, &v.High, &v.Low, &v.Close, &v.Volume, &v.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if v.Time == 0 {
+			v.Time = at
+		}
+		v.Symbol = symbol
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// inTx 在一个事务中使用预编译的语句 name
+func (s *SQL) inTx(ctx context.Context, name string, fn func(stmt *sql.Stmt) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := tx.StmtContext(ctx, s.stmts[name])
+	defer stmt.Close()
+
+	if err := fn(stmt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+// Package store 数据存储，main 只通过 Repository 读写数据，不直接写 SQL。
+// SQL 为 sqlite 和 postgres 的实现，Memory 为内存实现
+package store
+
+import (
+	"context"
+	"go-colly/util"
+	"time"
+)
+
+// Stock stock 表
+type Stock struct {
+	ID     int
+	Name   string
+	Code   string
+	Place  string
+	Status int
+}
+
+// Target stock_target 表，财报指标
+type Target struct {
+	ID   int
+	Name string
+}
+
+// DataPoint stock_data 中的一条财报数据，Period 如 2024Q1、2024
+type DataPoint struct {
+	StockID    int
+	StockName  string
+	TargetID   int
+	TargetName string
+	Period     string
+	Value      float64
+}
+
+// Repository 股票、指标、财报数据、K 线和报价快照的读写
+type Repository interface {
+	// Stocks status 为 1 的股票，按 id 排序，limit 为 0 时不限制
+	Stocks(ctx context.Context, limit int) ([]Stock, error)
+	// Targets 所有指标，按 id 排序
+	Targets(ctx context.Context) ([]Target, error)
+	// StockData 一只股票的所有财报数据，按指标 id 排序
+	StockData(ctx context.Context, stockID int) ([]DataPoint, error)
+	// Periods stock_data 中出现过的所有财报期，不保证顺序，排序用 ReportPeriod
+	Periods(ctx context.Context) ([]string, error)
+	// InsertStockData 在一个事务中写入财报数据，只使用 StockID、TargetID、Period、Value
+	InsertStockData(ctx context.Context, points []DataPoint) error
+
+	util.KlineStore
+	// LoadKlines 保存的 K 线，从早到晚
+	LoadKlines(ctx context.Context, symbol string, period util.Period) ([]util.KlineData, error)
+	// TradingDays 有日 K 的交易日，symbol 为空时为所有品种
+	TradingDays(ctx context.Context, symbol string) ([]time.Time, error)
+
+	util.SnapshotStore
+	// LoadSnapshots 某个品种 from 到 to 之间保存的报价，从早到晚
+	LoadSnapshots(ctx context.Context, symbol string, from, to time.Time) ([]util.KlineData, error)
+
+	Close() error
+}
+
+var (
+	_ Repository = (*SQL)(nil)
+	_ Repository = (*Memory)(nil)
+)
@@ -0,0 +1,31 @@
+-- db copy 测试用的 sqlite 数据，在迁移后的空库中执行
+INSERT INTO stock (id, name, code, place, status) VALUES
+	(1, '平安银行', '000001', 'sz', 1),
+	(2, '浦发银行', '600000', 'sh', 1),
+	(5, '退市股票', '600005', 'sh', 0);
+
+INSERT INTO stock_target (id, name) VALUES
+	(1, '营业收入'),
+	(3, '净利润');
+
+-- 同一股票、指标、财报期有两行，period 为 NULL 的也要复制
+INSERT INTO stock_data (id, stock_id, period, target_id, data) VALUES
+	(10, 1, '2024Q1', 1, 100.5),
+	(11, 1, '2024Q1', 3, 20.25),
+	(12, 1, '2024', 1, 400),
+	(13, 1, '2024', 1, 400),
+	(14, 2, NULL, 3, 1.5),
+	(20, 2, '2023', 1, 300);
+
+INSERT INTO kline (symbol, period, trading_day, time, open, high, low, close, volume, amount, pre_close) VALUES
+	('sz000001', 'DAY', 1728403200, 1728457200, 10.8, 11.2, 10.5, 11, 1500000000, 16500000000.5, 10.9),
+	('sz000001', 'DAY', 1728489600, 1728543600, 11, 11.1, 10.6, 10.7, 900000000, 9630000000, 11),
+	('sh600000', 'DAY', 1728489600, 1728543600, 8.1, 8.3, 8, 8.2, 50000000, 410000000, 8.1),
+	('sz000001', 'MIN1', 1728489600, 1728523860, 11, 11.05, 10.98, 11.02, 120000, 1322400, 11);
+
+-- 同一时间拉取了两次同样的报价
+INSERT INTO quote_snapshot (id, symbol, time, quote_time, pre_close, open, high, low, close, volume, amount) VALUES
+	(100, 'sz000001', 1728527400, 1728527398, 11, 11, 11.1, 10.9, 11.05, 300000000, 3300000000),
+	(101, 'sh600000', 1728527400, 1728527399, 8.1, 8.1, 8.2, 8.05, 8.15, 10000000, 81500000),
+	(102, 'sz000001', 1728527405, 1728527403, 11, 11, 11.1, 10.9, 11.06, 300100000, 3301100000),
+	(103, 'sz000001', 1728527405, 1728527403, 11, 11, 11.1, 10.9, 11.06, 300100000, 3301100000);
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-colly/calendar"
+	"go-colly/store"
+	"go-colly/util"
+	"log"
+	"os"
+	"time"
+)
+
+// tradingDays 交易日历
+// go-colly calendar days -from 2024-09-30 -to 2024-10-10
+// go-colly calendar rebuild -symbol sz002139 -o holidays.txt
+func tradingDays(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: go-colly calendar days|rebuild [flags]")
+	}
+
+	switch args[0] {
+	case "days":
+		fs := flag.NewFlagSet("calendar days", flag.ExitOnError)
+		from := fs.String("from", time.Now().Format("2006-01-02"), "first date")
+		to := fs.String("to", "", "last date, defaults to -from")
+		fs.Parse(args[1:])
+		if *to == "" {
+			*to = *from
+		}
+
+		start, err := time.ParseInLocation("2006-01-02", *from, calendar.Location())
+		if err != nil {
+			log.Fatal(err)
+		}
+		end, err := time.ParseInLocation("2006-01-02", *to, calendar.Location())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cal := loadCalendar()
+		if !cal.Covers(end) {
+			log.Printf("warning: holiday calendar ends in %d, later dates only skip weekends", cal.LastYear())
+		}
+		days := cal.Between(start, end)
+		for _, d := range days {
+			fmt.Println(d.Format("2006-01-02 Mon"))
+		}
+		fmt.Printf("%d trading days, previous %s, next %s\n", len(days),
+			cal.Prev(start).Format("2006-01-02"), cal.Next(end).Format("2006-01-02"))
+	case "rebuild":
+		fs := flag.NewFlagSet("calendar rebuild", flag.ExitOnError)
+		symbol := fs.String("symbol", "", "use the DAY K-lines of one symbol, e.g. sz002139; empty for all")
+		out := fs.String("o", "", "write to file instead of stdout")
+		fs.Parse(args[1:])
+
+		loadConfig()
+		repo, err := store.Open()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer repo.Close()
+
+		days, err := repo.TradingDays(context.Background(), *symbol)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(days) == 0 {
+			log.Fatalf("no DAY K-lines for %q, run backfill first", *symbol)
+		}
+		cal, err := calendar.Rebuild(days, loadCalendar())
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := os.Stdout
+		if *out != "" {
+			if w, err = os.Create(*out); err != nil {
+				log.Fatal(err)
+			}
+			defer w.Close()
+		}
+		if err := cal.Write(w); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown calendar command %q", args[0])
+	}
+}
+
+// loadCalendar 配置了 holiday_file 时读取该文件，否则使用内置的日历
+func loadCalendar() *calendar.Calendar {
+	conf, err := util.ParseConfigFile()
+	if err != nil || conf.Settings.HolidayFile == "" {
+		return calendar.Default()
+	}
+	cal, err := calendar.Load(conf.Settings.HolidayFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cal
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"fmt"
+	"go-colly/util"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/mattn/go-runewidth"
+)
+
+// tui 全屏监控，只用键盘操作，SSH 下也可以使用
+//
+//	↑/↓ 或 k/j  移动光标          ←/→ 或 h/l  选择排序列
+//	s           切换升序/降序     g           按分组过滤
+//	Enter       打开/关闭详情     Space 或 p  暂停/继续
+//	+/-         调整刷新间隔      q 或 Esc    退出
+type monitor struct {
+	screen tcell.Screen
+
+	mu       sync.Mutex
+	paused   bool
+	interval time.Duration // 按 +/- 设置的刷新间隔，为 0 时跟随配置文件的 poll_interval
+	wake     chan struct{}
+
+	rows    []util.KlineData
+	groups  map[string]string // symbol -> group
+	err     error
+	logged  string // 最近一条 log，运行期间 log 不输出到屏幕
+	updated time.Time
+
+	cursor   int
+	offset   int
+	sortCol  int // -1 表示按自选列表的顺序
+	sortDesc bool
+	group    string // 为空时显示全部
+	detail   bool
+}
+
+// quotesEvent 一轮行情拉取完成
+type quotesEvent struct {
+	tcell.EventTime
+	rows   []util.KlineData
+	groups map[string]string
+	err    error
+}
+
+// logEvent log 输出了一行
+type logEvent struct {
+	tcell.EventTime
+	line string
+}
+
+// logBuffer tcell 占用屏幕时代替 log 的输出，保存最近的 logBufferLines 行，退出后写回原来的输出
+type logBuffer struct {
+	screen tcell.Screen
+
+	mu    sync.Mutex
+	lines []string
+}
+
+const logBufferLines = 200
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	b.mu.Lock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > logBufferLines {
+		b.lines = b.lines[len(b.lines)-logBufferLines:]
+	}
+	b.mu.Unlock()
+
+	ev := &logEvent{line: line}
+	ev.SetEventNow()
+	b.screen.PostEvent(ev)
+	return len(p), nil
+}
+
+// flush 把保存的 log 写到 w
+func (b *logBuffer) flush(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, line := range b.lines {
+		fmt.Fprintln(w, line)
+	}
+	b.lines = nil
+}
+
+const (
+	minInterval = time.Second
+	maxInterval = 5 * time.Minute
+)
+
+func runTUI() error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	// log 直接写 stderr 会弄乱屏幕，先保存下来，screen.Fini 之后再输出
+	logs := &logBuffer{screen: screen}
+	prev := log.Writer()
+	log.SetOutput(logs)
+	defer func() {
+		log.SetOutput(prev)
+		logs.flush(prev)
+	}()
+	defer screen.Fini()
+	screen.HideCursor()
+
+	m := &monitor{
+		screen:  screen,
+		wake:    make(chan struct{}, 1),
+		sortCol: -1,
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	go m.poll(quit)
+
+	m.draw()
+	for {
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *quotesEvent:
+			m.rows, m.groups, m.err = ev.rows, ev.groups, ev.err
+			if ev.err == nil {
+				m.updated = ev.When()
+			}
+		case *logEvent:
+			m.logged = ev.line
+		case *tcell.EventKey:
+			if !m.handleKey(ev) {
+				return nil
+			}
+		}
+		m.draw()
+	}
+}
+
+// poll 交易时段内按刷新间隔拉取行情，暂停时不拉取
+func (m *monitor) poll(quit <-chan struct{}) {
+	for {
+		m.mu.Lock()
+		paused := m.paused
+		m.mu.Unlock()
+		interval := m.currentInterval()
+
+		// 非交易时段不拉取，只重画交易状态
+		if paused || !util.DefaultScheduler.Due(time.Now()) {
+			m.screen.PostEvent(tcell.NewEventInterrupt(nil))
+		} else {
+			ev := &quotesEvent{}
+			ev.rows, ev.err = util.GetStockData("")
+			if ev.err == nil {
+				saveSnapshots(time.Now(), ev.rows)
+			}
+			if conf, err := util.DefaultConfigWatcher.Config(); err == nil {
+				ev.groups = make(map[string]string)
+				for _, v := range conf.Instruments {
+					ev.groups[v.Symbol()] = v.Group
+				}
+			}
+			ev.SetEventNow()
+			m.screen.PostEvent(ev)
+		}
+
+		select {
+		case <-quit:
+			return
+		case <-m.wake:
+		case <-time.After(util.DefaultScheduler.Wait(time.Now(), interval)):
+		}
+	}
+}
+
+// handleKey 返回 false 时退出
+func (m *monitor) handleKey(ev *tcell.EventKey) bool {
+	cols := util.TableColumns(m.rows)
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		return false
+	case tcell.KeyUp:
+		m.cursor--
+	case tcell.KeyDown:
+		m.cursor++
+	case tcell.KeyPgUp:
+		m.cursor -= m.pageSize()
+	case tcell.KeyPgDn:
+		m.cursor += m.pageSize()
+	case tcell.KeyHome:
+		m.cursor = 0
+	case tcell.KeyEnd:
+		m.cursor = len(m.rows)
+	case tcell.KeyLeft:
+		m.moveSort(-1, len(cols))
+	case tcell.KeyRight:
+		m.moveSort(1, len(cols))
+	case tcell.KeyEnter:
+		m.detail = !m.detail
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case 'k':
+			m.cursor--
+		case 'j':
+			m.cursor++
+		case 'h':
+			m.moveSort(-1, len(cols))
+		case 'l':
+			m.moveSort(1, len(cols))
+		case 's':
+			m.sortDesc = !m.sortDesc
+		case 'g':
+			m.nextGroup()
+		case ' ', 'p':
+			m.mu.Lock()
+			m.paused = !m.paused
+			m.mu.Unlock()
+			m.kick()
+		case '+', '=':
+			m.setInterval(m.currentInterval() * 2)
+		case '-', '_':
+			m.setInterval(m.currentInterval() / 2)
+		case 'r':
+			m.kick()
+		}
+	}
+	return true
+}
+
+// moveSort 在列之间移动排序列，-1 为不排序
+func (m *monitor) moveSort(step int, n int) {
+	m.sortCol += step
+	if m.sortCol < -1 {
+		m.sortCol = n - 1
+	}
+	if m.sortCol >= n {
+		m.sortCol = -1
+	}
+}
+
+func (m *monitor) nextGroup() {
+	var groups []string
+	seen := map[string]bool{}
+	for _, v := range m.rows {
+		if g := m.groups[v.Symbol]; g != "" && !seen[g] {
+			seen[g] = true
+			groups = append(groups, g)
+		}
+	}
+	sort.Strings(groups)
+	next := ""
+	for i, g := range groups {
+		if g == m.group && i+1 < len(groups) {
+			next = groups[i+1]
+			break
+		}
+	}
+	if m.group == "" && len(groups) > 0 {
+		next = groups[0]
+	}
+	m.group = next
+	m.cursor = 0
+}
+
+// currentInterval 没有按过 +/- 时使用当前配置的 poll_interval，修改配置文件后立即生效
+func (m *monitor) currentInterval() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.interval > 0 {
+		return m.interval
+	}
+	return util.ActiveSettings().PollInterval
+}
+
+func (m *monitor) setInterval(d time.Duration) {
+	if d < minInterval {
+		d = minInterval
+	}
+	if d > maxInterval {
+		d = maxInterval
+	}
+	m.mu.Lock()
+	m.interval = d
+	m.mu.Unlock()
+}
+
+// kick 立即拉取一次
+func (m *monitor) kick() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// visible 过滤、排序后的行
+func (m *monitor) visible(cols []util.Column) []util.KlineData {
+	rows := make([]util.KlineData, 0, len(m.rows))
+	for _, v := range m.rows {
+		if m.group == "" || m.groups[v.Symbol] == m.group {
+			rows = append(rows, v)
+		}
+	}
+	if m.sortCol < 0 || m.sortCol >= len(cols) {
+		return rows
+	}
+
+	c := cols[m.sortCol]
+	less := func(a, b util.KlineData) bool {
+		if c.Sort != nil {
+			return c.Sort(a) < c.Sort(b)
+		}
+		return c.Value(a, c.Precision) < c.Value(b, c.Precision)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if m.sortDesc {
+			return less(rows[j], rows[i])
+		}
+		return less(rows[i], rows[j])
+	})
+	return rows
+}
+
+// pageSize 表格区域能显示的行数
+func (m *monitor) pageSize() int {
+	_, h := m.screen.Size()
+	n := h - 4 // 标题、表头、底部两行
+	if m.detail {
+		n -= detailHeight(h)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func detailHeight(h int) int {
+	return h / 2
+}
+
+func (m *monitor) draw() {
+	s := m.screen
+	s.Clear()
+	w, h := s.Size()
+	theme := tuiTheme()
+
+	// 标题
+	m.mu.Lock()
+	paused := m.paused
+	m.mu.Unlock()
+	interval := m.currentInterval()
+	status := "live"
+	if paused {
+		status = "paused"
+	}
+	group := "all"
+	if m.group != "" {
+		group = m.group
+	}
+	updated := "-"
+	if !m.updated.IsZero() {
+		updated = m.updated.Format("15:04:05")
+	}
+	title := fmt.Sprintf(" go-colly  %s  every %s  group: %s  updated %s", status, interval, group, updated)
+	drawText(s, 0, 0, w, title, tcell.StyleDefault.Reverse(true))
+
+	cols := util.TableColumns(m.rows)
+	rows := m.visible(cols)
+	if m.cursor >= len(rows) {
+		m.cursor = len(rows) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	page := m.pageSize()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+
+	// 列宽
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		widths[i] = runewidth.StringWidth(c.Header) + 2 // 留出排序标记
+	}
+	for r, v := range rows {
+		if v.Stale {
+			v.StockName += " *"
+		}
+		cells[r] = make([]string, len(cols))
+		for i, c := range cols {
+			cells[r][i] = c.Cell(v)
+			if n := runewidth.StringWidth(cells[r][i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	// 表头
+	x := 0
+	for i, c := range cols {
+		header := c.Header
+		style := tcell.StyleDefault.Bold(true)
+		if i == m.sortCol {
+			mark := " ▲"
+			if m.sortDesc {
+				mark = " ▼"
+			}
+			header += mark
+			style = style.Underline(true)
+		}
+		drawText(s, x, 1, widths[i], alignText(header, widths[i], text.AlignCenter), style)
+		x += widths[i] + 1
+	}
+
+	var stale, failed bool
+	for r := m.offset; r < len(rows) && r < m.offset+page; r++ {
+		v := rows[r]
+		stale = stale || v.Stale
+		failed = failed || v.Failed
+		x = 0
+		for i, c := range cols {
+			style := tcell.StyleDefault
+			if c.Change != nil && !v.Failed {
+				style = theme.style(c.Change(v))
+			}
+			if r == m.cursor {
+				style = style.Reverse(true)
+			}
+			drawText(s, x, 2+r-m.offset, widths[i]+1, alignText(cells[r][i], widths[i], c.Align)+" ", style)
+			x += widths[i] + 1
+		}
+	}
+
+	// 详情面板显示当前行的分时，下一轮拉取它的分时
+	if m.detail && m.cursor < len(rows) {
+		util.Intraday.Watch(rows[m.cursor].Symbol)
+		top := h - 2 - detailHeight(h)
+		m.drawDetail(rows[m.cursor], top, w, detailHeight(h))
+	} else {
+		util.Intraday.Watch()
+	}
+
+	// 底部：提示和帮助
+	var notes []string
+	if m.err != nil {
+		notes = append(notes, "error: "+m.err.Error())
+	} else if m.logged != "" {
+		notes = append(notes, "log: "+m.logged)
+	}
+	notes = append(notes, util.DefaultScheduler.Status(time.Now()))
+	if n := util.DefaultConfigWatcher.Notice(); n != "" {
+		notes = append(notes, n)
+	}
+	if stale {
+		notes = append(notes, "* stale")
+	}
+	if failed {
+		notes = append(notes, util.FailedPlaceholder+" no quote yet")
+	}
+	drawText(s, 0, h-2, w, strings.Join(notes, "  "), tcell.StyleDefault.Foreground(tcell.ColorYellow))
+	help := " ↑↓ move  ←→ sort  s asc/desc  g group  Enter detail  Space pause  +/- interval  r refresh  q quit"
+	drawText(s, 0, h-1, w, help, tcell.StyleDefault.Reverse(true))
+	s.Show()
+}
+
+// drawDetail 当前行的基本面、分时走势和盘口
+func (m *monitor) drawDetail(v util.KlineData, top, w, h int) {
+	lines := []string{
+		strings.Repeat("─", w),
+		fmt.Sprintf("%s %s  %s", v.StockCode, v.StockName, m.groups[v.Symbol]),
+	}
+	var panel []string
+	if v.Failed {
+		lines = append(lines, "No quote yet")
+	} else {
+		lines = append(lines,
+			fmt.Sprintf("Close %.3f  PreClose %.3f  Open %.3f  High %.3f  Low %.3f  Volume %d  Amount %.0f",
+				v.Close, v.PreClose, v.Open, v.High, v.Low, v.Volume, v.Amount))
+		if series, ok := util.Intraday.Latest(v.Symbol); ok {
+			lines = append(lines, "Intraday "+series.Date+"  "+util.Sparkline(series.Minutes, 48))
+		}
+		if f := v.Fundamentals; f != nil {
+			lines = append(lines,
+				fmt.Sprintf("Status %s  %s  Up %.3f  Down %.3f  52W %.3f ~ %.3f  WAvg %.3f",
+					f.TradeStatus, f.Time().Format("2006-01-02 15:04:05"), f.UpPx, f.DownPx, f.W52LowPx, f.W52HighPx, f.WAvgPx),
+				fmt.Sprintf("PE %.2f  PE(TTM) %.2f  PB %.2f  Turnover %.2f%%  VolRatio %.2f  Amplitude %.2f  MarketValue %.0f",
+					f.PeRate, f.TtmPeRate, f.DynPbRate, f.TurnoverRatio, f.VolRatio, f.Amplitude, f.MarketValue),
+			)
+			if book, err := f.OrderBook(); err != nil {
+				lines = append(lines, err.Error())
+			} else if !book.Empty() {
+				panel = strings.Split(util.BuildDepthPanel("Order book", book), "\n")
+			}
+		}
+	}
+
+	// 盘口放在右边，屏幕太窄时放在下面
+	left := w
+	if len(panel) > 0 {
+		pw := runewidth.StringWidth(panel[0])
+		if w-pw >= 60 {
+			left = w - pw - 1
+			for i, line := range panel {
+				if i+1 >= h {
+					break
+				}
+				drawText(m.screen, left+1, top+1+i, pw, line, tcell.StyleDefault)
+			}
+		} else {
+			lines = append(lines, panel...)
+		}
+	}
+	for i, line := range lines {
+		if i >= h {
+			break
+		}
+		width := left
+		if i == 0 {
+			width = w
+		}
+		drawText(m.screen, 0, top+i, width, line, tcell.StyleDefault)
+	}
+}
+
+// drawText 从 (x, y) 开始写，超过 width 的部分截断，中文占两列
+func drawText(s tcell.Screen, x, y, width int, str string, style tcell.Style) {
+	end := x + width
+	for _, r := range str {
+		rw := runewidth.RuneWidth(r)
+		if x+rw > end {
+			break
+		}
+		s.SetContent(x, y, r, nil, style)
+		x += rw
+	}
+}
+
+func alignText(str string, width int, align text.Align) string {
+	pad := width - runewidth.StringWidth(str)
+	if pad <= 0 {
+		return str
+	}
+	switch align {
+	case text.AlignRight:
+		return strings.Repeat(" ", pad) + str
+	case text.AlignCenter:
+		return strings.Repeat(" ", pad/2) + str + strings.Repeat(" ", pad-pad/2)
+	}
+	return str + strings.Repeat(" ", pad)
+}
+
+// tcellTheme 把 util.Theme 的 ANSI 颜色转换成 tcell 的样式
+type tcellTheme struct {
+	up, down, flat tcell.Style
+}
+
+func tuiTheme() tcellTheme {
+	t := util.ActiveTheme()
+	return tcellTheme{up: tcellStyle(t.Up), down: tcellStyle(t.Down), flat: tcellStyle(t.Flat)}
+}
+
+func (t tcellTheme) style(change float64) tcell.Style {
+	switch {
+	case change > 0:
+		return t.up
+	case change < 0:
+		return t.down
+	}
+	return t.flat
+}
+
+func tcellStyle(colors text.Colors) tcell.Style {
+	style := tcell.StyleDefault
+	for _, c := range colors {
+		switch {
+		case c == text.Bold:
+			style = style.Bold(true)
+		case c >= text.FgBlack && c <= text.FgWhite:
+			style = style.Foreground(tcell.PaletteColor(int(c - text.FgBlack)))
+		case c >= text.FgHiBlack && c <= text.FgHiWhite:
+			style = style.Foreground(tcell.PaletteColor(int(c-text.FgHiBlack) + 8))
+		}
+	}
+	return style
+}
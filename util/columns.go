@@ -0,0 +1,325 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+watchlist.yaml 中的表格列，按顺序显示。可以只写列名，也可以写成对象修改标题、对齐和小数位数
+
+settings:
+  columns:
+    - code
+    - name
+    - current
+    - change_pct
+    - {key: amount, header: 成交额, align: right}
+    - {key: high, precision: 2}
+*/
+
+// ColumnSpec 配置中的一列
+type ColumnSpec struct {
+	Key       string `yaml:"key"`
+	Header    string `yaml:"header,omitempty"`    // 为空时用默认标题
+	Align     string `yaml:"align,omitempty"`     // left、center、right，为空时用默认对齐
+	Precision *int   `yaml:"precision,omitempty"` // 小数位数，为空时用默认值
+}
+
+// UnmarshalYAML 支持只写列名
+func (c *ColumnSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = ColumnSpec{Key: node.Value}
+		return nil
+	}
+	type plain ColumnSpec
+	return node.Decode((*plain)(c))
+}
+
+// MarshalYAML 只有列名时写成字符串
+func (c ColumnSpec) MarshalYAML() (interface{}, error) {
+	if c.Header == "" && c.Align == "" && c.Precision == nil {
+		return c.Key, nil
+	}
+	type plain ColumnSpec
+	return plain(c), nil
+}
+
+// Column 目录中的一列
+type Column struct {
+	Key       string
+	Header    string
+	Align     text.Align
+	Precision int
+	// Value 格式化这一列，prec 为小数位数
+	Value func(v KlineData, prec int) string
+	// Change 决定颜色的涨跌值，为 nil 时不上色
+	Change func(v KlineData) float64
+	// Sort 排序用的数值，为 nil 时按 Value 的文字排序
+	Sort func(v KlineData) float64
+}
+
+// FailedPlaceholder 从来没有拿到过数据的品种，除代码和名称外显示的值
+const FailedPlaceholder = "--"
+
+// Cell 单元格的文字，Failed 的品种除代码和名称外显示 FailedPlaceholder
+func (c Column) Cell(v KlineData) string {
+	if v.Failed && c.Key != "code" && c.Key != "name" {
+		return FailedPlaceholder
+	}
+	return c.Value(v, c.Precision)
+}
+
+// DefaultColumns 没有配置 columns 时显示的列，intraday 只在有分时数据时显示
+var DefaultColumns = []string{"code", "name", "yesterday", "current", "open", "high", "low", "intraday"}
+
+var columnCatalog = map[string]Column{}
+
+func init() {
+	for _, c := range []Column{
+		{Key: "code", Header: "Code", Align: text.AlignLeft, Value: func(v KlineData, prec int) string { return v.StockCode }},
+		{Key: "name", Header: "Name", Align: text.AlignLeft, Value: func(v KlineData, prec int) string { return v.StockName }},
+		{Key: "yesterday", Header: "Yesterday", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			return fmt.Sprintf("%.*f", prec, v.PreClose)
+		}, Sort: func(v KlineData) float64 { return v.PreClose }},
+		{Key: "current", Header: "Current", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			return priceWithChange(v.Close, v.PreClose, prec)
+		}, Change: func(v KlineData) float64 { return priceChange(v.Close, v.PreClose) }, Sort: func(v KlineData) float64 { return v.Close }},
+		{Key: "open", Header: "Open", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			return priceWithChange(v.Open, v.PreClose, prec)
+		}, Change: func(v KlineData) float64 { return priceChange(v.Open, v.PreClose) }, Sort: func(v KlineData) float64 { return v.Open }},
+		{Key: "high", Header: "High", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			return priceWithChange(v.High, v.PreClose, prec)
+		}, Change: func(v KlineData) float64 { return priceChange(v.High, v.PreClose) }, Sort: func(v KlineData) float64 { return v.High }},
+		{Key: "low", Header: "Low", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			return priceWithChange(v.Low, v.PreClose, prec)
+		}, Change: func(v KlineData) float64 { return priceChange(v.Low, v.PreClose) }, Sort: func(v KlineData) float64 { return v.Low }},
+		{Key: "change", Header: "Change", Align: text.AlignRight, Precision: 3, Value: func(v KlineData, prec int) string {
+			if v.Close <= 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%+.*f", prec, v.Close-v.PreClose)
+		}, Change: closeChange, Sort: closeChange},
+		{Key: "change_pct", Header: "Change%", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			if v.Close <= 0 || v.PreClose <= 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%+.*f%%", prec, 100*(v.Close-v.PreClose)/v.PreClose)
+		}, Change: closeChange, Sort: changePct},
+		{Key: "amplitude", Header: "Amplitude", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			if v.High <= 0 || v.Low <= 0 || v.PreClose <= 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.*f%%", prec, 100*(v.High-v.Low)/v.PreClose)
+		}, Sort: func(v KlineData) float64 { return ratio(v.High-v.Low, v.PreClose) }},
+		{Key: "volume", Header: "Volume", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			return formatWan(float64(v.Volume)/100, prec) + "手"
+		}, Sort: func(v KlineData) float64 { return float64(v.Volume) }},
+		{Key: "amount", Header: "Amount", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			return formatWan(v.Amount, prec)
+		}, Sort: func(v KlineData) float64 { return v.Amount }},
+		{Key: "after_trade_volume", Header: "AfterTrade", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			return formatWan(float64(v.AfterTradeVolume)/100, prec) + "手"
+		}, Sort: func(v KlineData) float64 { return float64(v.AfterTradeVolume) }},
+		{Key: "w52_position", Header: "52W", Align: text.AlignRight, Precision: 0, Value: func(v KlineData, prec int) string {
+			// 现价在 52 周最低到最高之间的位置，只有基本面接口有 52 周高低
+			f := v.Fundamentals
+			if f == nil || f.W52HighPx <= f.W52LowPx || v.Close <= 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.*f%%", prec, 100*(v.Close-f.W52LowPx)/(f.W52HighPx-f.W52LowPx))
+		}, Sort: w52Position},
+		{Key: "turnover", Header: "Turnover", Align: text.AlignRight, Precision: 2, Value: func(v KlineData, prec int) string {
+			if v.Fundamentals == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%.*f%%", prec, v.Fundamentals.TurnoverRatio)
+		}, Sort: func(v KlineData) float64 { return fundamental(v).TurnoverRatio }},
+		{Key: "intraday", Header: "Intraday", Align: text.AlignLeft, Value: func(v KlineData, prec int) string {
+			series, _ := Intraday.Latest(v.Symbol)
+			return Sparkline(series.Minutes, 24)
+		}, Change: closeChange, Sort: changePct},
+	} {
+		columnCatalog[c.Key] = c
+	}
+}
+
+// ColumnKeys 目录中所有列名
+func ColumnKeys() []string {
+	keys := make([]string, 0, len(columnCatalog))
+	for k := range columnCatalog {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate 检查列名、对齐方式和小数位数
+func (c ColumnSpec) Validate() error {
+	if _, ok := columnCatalog[c.Key]; !ok {
+		return fmt.Errorf("unknown column %q, available: %s", c.Key, strings.Join(ColumnKeys(), ", "))
+	}
+	if _, ok := parseAlign(c.Align); !ok {
+		return fmt.Errorf("column %s: align must be left, center or right, got %q", c.Key, c.Align)
+	}
+	if c.Precision != nil && (*c.Precision < 0 || *c.Precision > 6) {
+		return fmt.Errorf("column %s: precision must be between 0 and 6", c.Key)
+	}
+	return nil
+}
+
+// column 目录中的列加上配置的修改
+func (c ColumnSpec) column() Column {
+	col := columnCatalog[c.Key]
+	if c.Header != "" {
+		col.Header = c.Header
+	}
+	if align, ok := parseAlign(c.Align); ok && c.Align != "" {
+		col.Align = align
+	}
+	if c.Precision != nil {
+		col.Precision = *c.Precision
+	}
+	return col
+}
+
+func parseAlign(s string) (text.Align, bool) {
+	switch strings.ToLower(s) {
+	case "":
+		return text.AlignDefault, true
+	case "left":
+		return text.AlignLeft, true
+	case "center":
+		return text.AlignCenter, true
+	case "right":
+		return text.AlignRight, true
+	}
+	return text.AlignDefault, false
+}
+
+// TableColumns 当前配置的列
+func TableColumns(result []KlineData) []Column {
+	return tableColumns(ActiveSettings().Columns, result)
+}
+
+// ColumnShown 当前配置的列中是否有 key
+func ColumnShown(key string) bool {
+	specs := ActiveSettings().Columns
+	if len(specs) == 0 {
+		for _, k := range DefaultColumns {
+			if k == key {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range specs {
+		if s.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// tableColumns 配置的列，没有配置时用 DefaultColumns。
+// intraday 在没有分时数据时不显示
+func tableColumns(specs []ColumnSpec, result []KlineData) []Column {
+	if len(specs) == 0 {
+		for _, k := range DefaultColumns {
+			specs = append(specs, ColumnSpec{Key: k})
+		}
+	}
+
+	var showIntraday bool
+	for _, v := range result {
+		if _, ok := Intraday.Latest(v.Symbol); ok {
+			showIntraday = true
+			break
+		}
+	}
+
+	cols := make([]Column, 0, len(specs))
+	for _, s := range specs {
+		if _, ok := columnCatalog[s.Key]; !ok {
+			continue
+		}
+		if s.Key == "intraday" && !showIntraday {
+			continue
+		}
+		cols = append(cols, s.column())
+	}
+	return cols
+}
+
+// setColumnConfigs 按列设置对齐
+func setColumnConfigs(t table.Writer, cols []Column) {
+	configs := make([]table.ColumnConfig, 0, len(cols))
+	for i, c := range cols {
+		configs = append(configs, table.ColumnConfig{
+			Number:      i + 1,
+			Align:       c.Align,
+			AlignHeader: text.AlignCenter,
+			AlignFooter: text.AlignCenter,
+		})
+	}
+	t.SetColumnConfigs(configs)
+}
+
+// priceChange 相对昨收的涨跌，价格或昨收缺失时为 0
+func priceChange(price, preClose float64) float64 {
+	if price <= 0 || preClose <= 0 {
+		return 0
+	}
+	return price - preClose
+}
+
+func closeChange(v KlineData) float64 { return priceChange(v.Close, v.PreClose) }
+
+func changePct(v KlineData) float64 { return ratio(closeChange(v), v.PreClose) }
+
+func w52Position(v KlineData) float64 {
+	f := fundamental(v)
+	return ratio(v.Close-f.W52LowPx, f.W52HighPx-f.W52LowPx)
+}
+
+// fundamental 没有基本面时返回零值
+func fundamental(v KlineData) Fundamentals {
+	if v.Fundamentals == nil {
+		return Fundamentals{}
+	}
+	return *v.Fundamentals
+}
+
+// ratio 分母不大于 0 时返回 0
+func ratio(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a / b
+}
+
+// priceWithChange 价格和相对昨收的涨跌幅，如 9.760 [-3.08%]
+func priceWithChange(price, preClose float64, prec int) string {
+	var perc float64
+	if price > 0 && preClose > 0 {
+		perc = (price - preClose) / preClose
+	}
+	return fmt.Sprintf("%.*f [%.2f%%]", prec, price, (math.Round(10000*perc))/100)
+}
+
+// formatWan 大于一万时用万、亿表示
+func formatWan(v float64, prec int) string {
+	switch abs := math.Abs(v); {
+	case abs >= 1e8:
+		return fmt.Sprintf("%.*f亿", prec, v/1e8)
+	case abs >= 1e4:
+		return fmt.Sprintf("%.*f万", prec, v/1e4)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
@@ -0,0 +1,206 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `settings:
+  poll_interval: 3s
+  providers: [tencent, sina]
+  db_path: db/test.db
+  output_dir: file
+  workers: 2
+  deadline: 2s
+  rate_limits:
+    qas.sylapp.cn: {rate: 3, burst: 5}
+  columns:
+    - code
+    - {key: amount, header: 成交额, precision: 1}
+  theme: western
+token: abc
+instruments:
+  - market: sz
+    code: "002139"
+    name: 拓邦
+    type: stock
+    group: stock
+  - market: SH
+    code: "510300"
+    type: etf
+    disabled: true
+`
+
+func TestParseConfig(t *testing.T) {
+	conf, err := ParseConfig("watchlist.yaml", []byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := conf.Settings
+	if s.PollInterval != 3*time.Second || s.Workers != 2 || s.Deadline != 2*time.Second || s.Theme != "western" {
+		t.Errorf("settings = %+v", s)
+	}
+	if !reflect.DeepEqual(s.Providers, []string{"tencent", "sina"}) || s.RateLimits["qas.sylapp.cn"] != (RateLimit{Rate: 3, Burst: 5}) {
+		t.Errorf("providers = %v, rate_limits = %v", s.Providers, s.RateLimits)
+	}
+	if len(s.Columns) != 2 || s.Columns[1].Header != "成交额" {
+		t.Errorf("columns = %+v", s.Columns)
+	}
+	want := []Instrument{
+		{Market: "SZ", Code: "002139", Name: "拓邦", Type: InstrumentStock, Group: "stock"},
+		{Market: "SH", Code: "510300", Name: "510300", Type: InstrumentETF, Disabled: true},
+	}
+	if conf.Token != "abc" || !reflect.DeepEqual(conf.Instruments, want) {
+		t.Errorf("token = %q, instruments = %+v", conf.Token, conf.Instruments)
+	}
+	if list := conf.Watchlist(); len(list) != 1 || list[0].Code != "002139" {
+		t.Errorf("Watchlist = %+v", list)
+	}
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	conf, err := ParseConfig("watchlist.yaml", []byte("instruments:\n  - {market: SZ, code: \"000001\", type: stock}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(conf.Settings, DefaultSettings) {
+		t.Errorf("settings = %+v, want DefaultSettings", conf.Settings)
+	}
+	if conf, err := ParseConfig("watchlist.yaml", nil); err != nil || len(conf.Instruments) != 0 {
+		t.Errorf("empty file = %+v, %v", conf, err)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	instrument := "instruments:\n  - {market: SZ, code: \"000001\", type: stock}\n"
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr []string
+	}{
+		{"misspelled setting", "settings:\n  poll_intervall: 3s\n" + instrument, []string{"line 2: field poll_intervall not found"}},
+		{"misspelled instrument field", "instruments:\n  - {market: SZ, code: \"000001\", typ: stock}\n", []string{"line 2: field typ not found"}},
+		{"unknown top-level key", "setting:\n  workers: 2\n" + instrument, []string{"line 1: field setting not found"}},
+		{"bad duration", "settings:\n  poll_interval: soon\n", []string{"watchlist.yaml: yaml:"}},
+		{"not yaml", "settings: [", []string{"watchlist.yaml: yaml:"}},
+		{
+			name: "invalid settings",
+			yaml: "settings:\n  poll_interval: 0s\n  workers: 0\n  deadline: -1s\n  db_path: \"\"\n  database: mysql://x\n  providers: [nope]\n  rate_limits:\n    a.com: {rate: 0, burst: 1}\n  theme: pink\n  columns: [code, bogus]\n",
+			wantErr: []string{
+				"watchlist.yaml:2: poll_interval must be positive",
+				"watchlist.yaml:3: workers must be positive",
+				"watchlist.yaml:4: deadline must not be negative",
+				"watchlist.yaml:5: db_path is empty",
+				"watchlist.yaml:6: database must be a postgres://",
+				`watchlist.yaml:7: unknown provider "nope"`,
+				"watchlist.yaml:8: rate limit of a.com",
+				"watchlist.yaml:10:",
+				"watchlist.yaml:11:",
+			},
+		},
+		{
+			name: "invalid instruments",
+			yaml: "instruments:\n  - {market: HK, code: \"00700\", type: stock}\n  - {market: SZ, code: \"000001\", type: bond}\n  - {market: SZ, code: \"000001\", type: stock}\n",
+			wantErr: []string{
+				`watchlist.yaml:2: market must be SH or SZ, got "HK"`,
+				`watchlist.yaml:2: code must be 6 digits, got "00700"`,
+				`watchlist.yaml:3: unknown instrument type "bond"`,
+				"watchlist.yaml:4: SZ 000001 already listed at line 3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig("watchlist.yaml", []byte(tt.yaml))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateCodeFile(t *testing.T) {
+	dir := t.TempDir()
+	defer func(name string) { tokenFile = name }(tokenFile)
+	tokenFile = filepath.Join(dir, "tokens.json")
+
+	src := filepath.Join(dir, "code.txt")
+	dst := filepath.Join(dir, "watchlist.yaml")
+	code := strings.Join([]string{
+		"token_abc-123",
+		"limit_qas.sylapp.cn_2_4",
+		"//stock",
+		"SZ_002139_拓邦(23~7)_1",
+		"SZ_000001_平安_银行_1",
+		"//etf",
+		"SH_513130_恒科_2",
+		"//SH_510300_300_2",
+		"// 注释: 不是分组",
+		"",
+	}, "\n")
+	if err := os.WriteFile(src, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateCodeFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	bt, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ParseConfig(dst, bt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Instrument{
+		{Market: "SZ", Code: "002139", Name: "拓邦(23~7)", Type: InstrumentStock, Group: "stock"},
+		{Market: "SZ", Code: "000001", Name: "平安_银行", Type: InstrumentStock, Group: "stock"},
+		{Market: "SH", Code: "513130", Name: "恒科", Type: InstrumentETF, Group: "etf"},
+		{Market: "SH", Code: "510300", Name: "300", Type: InstrumentETF, Group: "etf", Disabled: true},
+	}
+	if !reflect.DeepEqual(conf.Instruments, want) {
+		t.Errorf("instruments = %+v\nwant %+v", conf.Instruments, want)
+	}
+	if conf.Settings.RateLimits["qas.sylapp.cn"] != (RateLimit{Rate: 2, Burst: 4}) {
+		t.Errorf("rate_limits = %v", conf.Settings.RateLimits)
+	}
+	// token 不写进配置文件，放到 tokens.json
+	if conf.Token != "" || strings.Contains(string(bt), "abc-123") {
+		t.Error("token was written to the config file")
+	}
+	if info, ok, err := NewTokenStore(tokenFile).Load("jfzt"); err != nil || !ok || info.Token != "abc-123" {
+		t.Errorf("stored token = %+v, %v, %v", info, ok, err)
+	}
+}
+
+func TestMigrateCodeFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "code.txt")
+	dst := filepath.Join(dir, "watchlist.yaml")
+	for _, tt := range []struct{ code, wantErr string }{
+		{"SZ_002139_拓邦_1\nSZ_000001_平安_3\n", "code.txt:2: unknown instrument type"},
+		{"limit_qas.sylapp.cn_x_4\n", "code.txt:1: invalid limit line"},
+		{"SZ_002139_拓邦_1\nSZ_002139_拓邦_1\n", "already listed"},
+	} {
+		if err := os.WriteFile(src, []byte(tt.code), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := MigrateCodeFile(src, dst)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("MigrateCodeFile(%q) = %v, want %q", tt.code, err, tt.wantErr)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("%s written for an invalid code.txt", dst)
+		}
+	}
+}
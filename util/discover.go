@@ -0,0 +1,125 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly"
+)
+
+// 九方智投是 Next.js 站点，登录用的 Token 写在 [market_symbol] 页面的 js chunk 里，
+// chunk 文件名带 hash，每次发布都会变。这里从页面的 script 标签或 _buildManifest.js 中找到当前的 chunk。
+
+const jfztSite = "https://stock.9fzt.com"
+
+// DiscoverySymbol 用来加载页面的股票，任意一只都可以
+var DiscoverySymbol = "sz_002139"
+
+var (
+	chunkMu  sync.Mutex
+	chunkURL = jfztSite + "/_next/static/chunks/pages/index/index/%5Bmarket_symbol%5D-c090f6b2b074bb192ec7.js"
+
+	chunkPathRegexp = regexp.MustCompile(`static/chunks/pages/index/index/(?:\[|%5B)market_symbol(?:\]|%5D)-[0-9a-zA-Z]+\.js`)
+)
+
+// DiscoverLoginChunk 加载 stock.9fzt.com/index/<symbol>.html，找到当前的页面 chunk 地址
+func DiscoverLoginChunk(ctx context.Context, symbol string) (string, error) {
+	var scripts []string
+	var buildID string
+
+	c := NewCollector(ctx)
+	c.OnHTML("script[src]", func(e *colly.HTMLElement) {
+		scripts = append(scripts, e.Request.AbsoluteURL(e.Attr("src")))
+	})
+	c.OnHTML("script#__NEXT_DATA__", func(e *colly.HTMLElement) {
+		var data struct {
+			BuildID string `json:"buildId"`
+		}
+		if json.Unmarshal([]byte(e.Text), &data) == nil {
+			buildID = data.BuildID
+		}
+	})
+
+	page := fmt.Sprintf("%s/index/%s.html", jfztSite, symbol)
+	if err := c.Visit(page); err != nil {
+		return "", fmt.Errorf("visit %s: %w", page, err)
+	}
+	// 限流等待时 ctx 结束，请求被 Abort，Visit 返回 nil
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("visit %s: %w", page, err)
+	}
+
+	// 1. 页面直接引用了 chunk
+	manifest := ""
+	for _, src := range scripts {
+		if chunkPathRegexp.MatchString(src) {
+			return src, nil
+		}
+		if strings.HasSuffix(src, "/_buildManifest.js") {
+			manifest = src
+		}
+	}
+
+	// 2. 从 _buildManifest.js 中找
+	if manifest == "" && buildID != "" {
+		manifest = fmt.Sprintf("%s/_next/static/%s/_buildManifest.js", jfztSite, buildID)
+	}
+	if manifest == "" {
+		return "", errors.New("neither page chunk nor build manifest found on " + page)
+	}
+
+	resp, err := HttpRequestContext(ctx, manifest, "GET", nil, "")
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", manifest, err)
+	}
+	path := chunkPathRegexp.FindString(resp)
+	if path == "" {
+		return "", errors.New("page chunk not found in " + manifest)
+	}
+	// manifest 里的 [ ] 没有转义
+	path = strings.NewReplacer("[", url.PathEscape("["), "]", url.PathEscape("]")).Replace(path)
+	return jfztSite + "/_next/" + path, nil
+}
+
+// FetchBootstrapToken 从页面 chunk 中找到登录用的 Token 参数。
+// 使用缓存的 chunk 地址，提取失败时重新查找 chunk 再试一次
+func FetchBootstrapToken(ctx context.Context) (string, error) {
+	chunkMu.Lock()
+	defer chunkMu.Unlock()
+
+	token, err := extractBootstrapToken(ctx, chunkURL)
+	if err == nil {
+		return token, nil
+	}
+
+	discovered, derr := DiscoverLoginChunk(ctx, DiscoverySymbol)
+	if derr != nil {
+		return "", errors.Join(err, derr)
+	}
+	token, err = extractBootstrapToken(ctx, discovered)
+	if err != nil {
+		return "", err
+	}
+	chunkURL = discovered
+	return token, nil
+}
+
+var bootstrapTokenRegexp = regexp.MustCompile(`Token:"(.{36})"`)
+
+func extractBootstrapToken(ctx context.Context, jsfile string) (string, error) {
+	resp, err := HttpRequestContext(ctx, jsfile, "GET", nil, "")
+	if err != nil {
+		return "", err
+	}
+	matches := bootstrapTokenRegexp.FindStringSubmatch(resp)
+	if len(matches) == 0 {
+		return "", errors.New("bootstrap token not found in " + jsfile)
+	}
+	return matches[1], nil
+}
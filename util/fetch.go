@@ -0,0 +1,106 @@
+package util
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// FetchOptions 拉取自选列表的并发设置
+type FetchOptions struct {
+	Workers  int           // 同时请求的数量
+	Deadline time.Duration // 每一轮的截止时间，超时的品种显示上一次的数据
+}
+
+var DefaultFetchOptions = FetchOptions{Workers: 4, Deadline: 4 * time.Second}
+
+// FetchOverride 命令行指定的并发参数，非零时覆盖配置文件
+var FetchOverride FetchOptions
+
+var (
+	lastGoodMu sync.Mutex
+	lastGood   = make(map[string]KlineData) // symbol -> 最近一次成功的报价
+)
+
+// FetchWatchlist 并发获取报价，结果和 list 的顺序一致。
+// 超时或失败的品种用上一次成功的数据，并标记为 Stale；从来没有成功过的标记为 Failed
+func FetchWatchlist(ctx context.Context, list []Instrument, opts FetchOptions) []KlineData {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+
+	result := make([]KlineData, len(list))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers && i < len(list); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				result[idx] = fetchOne(ctx, list[idx])
+			}
+		}()
+	}
+
+	for idx := range list {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 从自选列表删除的品种不再保留数据
+	symbols := make(map[string]bool, len(list))
+	for _, ins := range list {
+		symbols[ins.Symbol()] = true
+	}
+	retainLastGood(symbols)
+	Intraday.Retain(symbols)
+
+	return result
+}
+
+// retainLastGood 只保留 symbols 中的品种
+func retainLastGood(symbols map[string]bool) {
+	lastGoodMu.Lock()
+	defer lastGoodMu.Unlock()
+	for symbol := range lastGood {
+		if !symbols[symbol] {
+			delete(lastGood, symbol)
+		}
+	}
+}
+
+func fetchOne(ctx context.Context, ins Instrument) KlineData {
+	symbol := ins.Symbol()
+
+	data, err := FetchQuote(ctx, ins)
+	// 报价之后再拉分时：主数据源是腾讯时这一轮已经拿到了分时，不用再请求
+	if ierr := FetchIntraday(ctx, ins); ierr != nil {
+		log.Println(ins.Code, "intraday:", ierr)
+	}
+
+	lastGoodMu.Lock()
+	if err == nil {
+		lastGood[symbol] = data
+	} else {
+		log.Println(ins.Code, err)
+		if prev, ok := lastGood[symbol]; ok {
+			data = prev
+			data.Stale = true
+		} else {
+			data = KlineData{Failed: true}
+		}
+	}
+	lastGoodMu.Unlock()
+
+	data.StockCode = ins.Code
+	data.StockName = ins.Name
+	data.Symbol = symbol
+	return data
+}
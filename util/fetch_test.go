@@ -0,0 +1,47 @@
+package util
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFetchWatchlistRetainsWatchlist(t *testing.T) {
+	lastGoodMu.Lock()
+	old := lastGood
+	lastGood = map[string]KlineData{
+		"sz000001": {Close: 10},
+		"sh600000": {Close: 7},
+	}
+	lastGoodMu.Unlock()
+	oldIntraday := Intraday
+	Intraday = NewIntradayStore()
+	t.Cleanup(func() {
+		lastGoodMu.Lock()
+		lastGood = old
+		lastGoodMu.Unlock()
+		Intraday = oldIntraday
+	})
+	Intraday.Update("sz000001", "20240517", minutes(1))
+	Intraday.Update("sh600000", "20240517", minutes(2))
+
+	// 没有数据源的类型直接失败，不会请求网络
+	list := []Instrument{{Market: "SZ", Code: "000001", Name: "平安银行", Type: "unknown"}}
+	got := FetchWatchlist(context.Background(), list, FetchOptions{Workers: 1})
+	if len(got) != 1 || !got[0].Stale || got[0].Close != 10 {
+		t.Errorf("got %+v, want the stale last good quote", got)
+	}
+
+	lastGoodMu.Lock()
+	_, kept := lastGood["sz000001"]
+	_, removed := lastGood["sh600000"]
+	lastGoodMu.Unlock()
+	if !kept || removed {
+		t.Errorf("lastGood = %v, want only sz000001", lastGood)
+	}
+	if _, ok := Intraday.Latest("sz000001"); !ok {
+		t.Error("intraday of sz000001 was removed")
+	}
+	if _, ok := Intraday.Latest("sh600000"); ok {
+		t.Error("intraday of sh600000 was kept")
+	}
+}
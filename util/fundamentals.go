@@ -0,0 +1,351 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 九方智投 ETF 基本面接口，返回值见 GetEtfDataFromJFZTContext 上方的注释
+const jfztFundamentalsURL = "https://hq.chongnengjihua.com/rjhy-gmg-quote/api/1/stock/getastockfundamentals?symbol="
+
+// fixedPointScale 接口中的价格是乘以 1000 之后的整数，3549 表示 3.549。
+// 用除法换算，3549*0.001 会得到 3.5490000000000004
+const fixedPointScale = 1000
+
+// Fundamentals 基本面接口的全部字段，价格已经乘以 0.001 换算成元，其余字段保持接口原值
+type Fundamentals struct {
+	ProdCode    string // 510300
+	ProdName    string // 300ETF
+	HqTypeCode  string // XSHG.EM.ETF
+	TradeStatus string // START、TRADE、BREAK、CLOSE 等
+	MarketDate  int64  // 20231025
+	// DataTimestamp HHMMSSmmm，90018370 表示 09:00:18.370
+	DataTimestamp int64
+
+	// 价格（元）
+	LastPx     float64
+	OpenPrice  float64
+	HighPx     float64
+	LowPx      float64
+	PreClosePx float64
+	UpPx       float64 // 涨停价
+	DownPx     float64 // 跌停价
+	W52HighPx  float64 // 52 周最高
+	W52LowPx   float64 // 52 周最低
+	WAvgPx     float64 // 均价
+	PxChange   float64 // 涨跌额
+	IpoPrice   float64
+
+	PxChangeRate  float64 // 涨跌幅
+	Amplitude     float64 // 振幅
+	Min5Chgpct    float64 // 5 分钟涨速
+	TurnoverRatio float64 // 换手率
+	VolRatio      float64 // 量比
+	EntrustRate   float64 // 委比
+	EntrustDiff   float64 // 委差
+
+	PeRate       float64
+	StaticPeRate float64
+	TtmPeRate    float64
+	DynPbRate    float64
+	Eps          float64
+	EpsTtm       float64
+	EpsYear      float64
+	Bps          float64
+	FinEndDate   int64
+	FinQuarter   int64
+	IssueDate    int64
+
+	BusinessAmount    int64   // 成交量（股）
+	BusinessAmountAm  int64   // 盘后成交量
+	BusinessAmountIn  int64   // 内盘
+	BusinessAmountOut int64   // 外盘
+	BusinessBalance   float64 // 成交额（元）
+	BusinessBalanceAm float64 // 盘后成交额
+	BusinessCount     int64   // 成交笔数
+	CurrentAmount     int64   // 现手
+	Day5Vol           float64 // 5 日均量
+	TradeMins         int64   // 已交易分钟数
+
+	TotalShares       float64
+	CirculationAmount float64 // 流通股本
+	CirculationValue  float64 // 流通市值
+	MarketValue       float64 // 总市值
+	SharesPerHand     int64
+	NeeqMakerCount    int64
+
+	TotalBidTurnover   float64
+	TotalOfferTurnover float64
+	TotalBuyAmount     float64
+	TotalSellAmount    float64
+	WithdrawBuyAmount  float64
+	WithdrawBuyNumber  int64
+	WithdrawSellAmount float64
+	WithdrawSellNumber int64
+
+	// BidGrp、OfferGrp 买卖盘口，保留接口原文，没有时为空
+	BidGrp   string
+	OfferGrp string
+}
+
+// Time 行情时间，MarketDate 和 DataTimestamp 合起来，按北京时间
+func (f Fundamentals) Time() time.Time {
+	if f.MarketDate == 0 {
+		return time.Time{}
+	}
+	d := f.MarketDate
+	ts := f.DataTimestamp
+	return time.Date(int(d/10000), time.Month(d/100%100), int(d%100),
+		int(ts/10000000), int(ts/100000%100), int(ts/1000%100), int(ts%1000)*int(time.Millisecond),
+		ShanghaiLocation())
+}
+
+// Kline 转成表格使用的报价
+func (f Fundamentals) Kline() KlineData {
+	da := KlineData{
+		High:      f.HighPx,
+		Open:      f.OpenPrice,
+		Low:       f.LowPx,
+		Close:     f.LastPx,
+		Volume:    f.BusinessAmount,
+		Amount:    f.BusinessBalance,
+		TickCount: f.BusinessCount,
+		PreClose:  f.PreClosePx,
+		StockCode: f.ProdCode,
+		StockName: f.ProdName,
+	}
+	if t := f.Time(); !t.IsZero() {
+		da.Time = t.Unix()
+	}
+	return da
+}
+
+// FieldError 某个字段的值无法解析
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: cannot decode %s: %v", e.Field, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// ParseFundamentals 解析基本面接口的 data。null 和缺少的字段为零值，
+// 数字写成字符串也可以，其他类型返回 *FieldError
+func ParseFundamentals(data json.RawMessage) (Fundamentals, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Fundamentals{}, err
+	}
+	if raw == nil {
+		return Fundamentals{}, errors.New("empty fundamentals data")
+	}
+
+	d := fieldDecoder{raw: raw}
+	f := Fundamentals{
+		ProdCode:      d.str("prodCode"),
+		ProdName:      strings.TrimSpace(d.str("prodName")),
+		HqTypeCode:    d.str("hqTypeCode"),
+		TradeStatus:   d.str("tradeStatus"),
+		MarketDate:    d.int("marketDate"),
+		DataTimestamp: d.int("dataTimestamp"),
+
+		LastPx:     d.price("lastPx"),
+		OpenPrice:  d.price("openPrice"),
+		HighPx:     d.price("highPx"),
+		LowPx:      d.price("lowPx"),
+		PreClosePx: d.price("preClosePx"),
+		UpPx:       d.price("upPx"),
+		DownPx:     d.price("downPx"),
+		W52HighPx:  d.price("w52HighPx"),
+		W52LowPx:   d.price("w52LowPx"),
+		WAvgPx:     d.price("wAvgPx"),
+		PxChange:   d.price("pxChange"),
+		IpoPrice:   d.price("ipoPrice"),
+
+		PxChangeRate:  d.float("pxChangeRate"),
+		Amplitude:     d.float("amplitude"),
+		Min5Chgpct:    d.float("min5Chgpct"),
+		TurnoverRatio: d.float("turnoverRatio"),
+		VolRatio:      d.float("volRatio"),
+		EntrustRate:   d.float("entrustRate"),
+		EntrustDiff:   d.float("entrustDiff"),
+
+		PeRate:       d.float("peRate"),
+		StaticPeRate: d.float("staticPeRate"),
+		TtmPeRate:    d.float("ttmPeRate"),
+		DynPbRate:    d.float("dynPbRate"),
+		Eps:          d.float("eps"),
+		EpsTtm:       d.float("epsTtm"),
+		EpsYear:      d.float("epsYear"),
+		Bps:          d.float("bps"),
+		FinEndDate:   d.int("finEndDate"),
+		FinQuarter:   d.int("finQuarter"),
+		IssueDate:    d.int("issueDate"),
+
+		BusinessAmount:    d.int("businessAmount"),
+		BusinessAmountAm:  d.int("businessAmountAm"),
+		BusinessAmountIn:  d.int("businessAmountIn"),
+		BusinessAmountOut: d.int("businessAmountOut"),
+		BusinessBalance:   d.float("businessBalance"),
+		BusinessBalanceAm: d.float("businessBalanceAm"),
+		BusinessCount:     d.int("businessCount"),
+		CurrentAmount:     d.int("currentAmount"),
+		Day5Vol:           d.float("day5Vol"),
+		TradeMins:         d.int("tradeMins"),
+
+		TotalShares:       d.float("totalShares"),
+		CirculationAmount: d.float("circulationAmount"),
+		CirculationValue:  d.float("circulationValue"),
+		MarketValue:       d.float("marketValue"),
+		SharesPerHand:     d.int("sharesPerHand"),
+		NeeqMakerCount:    d.int("neeqMakerCount"),
+
+		TotalBidTurnover:   d.float("totalBidTurnover"),
+		TotalOfferTurnover: d.float("totalOfferTurnover"),
+		TotalBuyAmount:     d.float("totalBuyAmount"),
+		TotalSellAmount:    d.float("totalSellAmount"),
+		WithdrawBuyAmount:  d.float("withdrawBuyAmount"),
+		WithdrawBuyNumber:  d.int("withdrawBuyNumber"),
+		WithdrawSellAmount: d.float("withdrawSellAmount"),
+		WithdrawSellNumber: d.int("withdrawSellNumber"),
+
+		BidGrp:   d.text("bidGrp"),
+		OfferGrp: d.text("offerGrp"),
+	}
+	if d.err != nil {
+		return Fundamentals{}, d.err
+	}
+	return f, nil
+}
+
+// fieldDecoder 逐个字段解析，记录第一个出错的字段
+type fieldDecoder struct {
+	raw map[string]json.RawMessage
+	err error
+}
+
+func (d *fieldDecoder) fail(key string, v json.RawMessage, err error) {
+	if d.err == nil {
+		d.err = &FieldError{Field: key, Value: string(v), Err: err}
+	}
+}
+
+// number 取出数字，null 和缺少的字段返回 false
+func (d *fieldDecoder) number(key string) (string, bool) {
+	v, ok := d.raw[key]
+	if !ok || bytes.Equal(v, []byte("null")) {
+		return "", false
+	}
+	var s string
+	if len(v) > 0 && v[0] == '"' {
+		if err := json.Unmarshal(v, &s); err != nil {
+			d.fail(key, v, err)
+			return "", false
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return "", false
+		}
+	} else {
+		var n json.Number
+		if err := json.Unmarshal(v, &n); err != nil {
+			d.fail(key, v, errors.New("not a number"))
+			return "", false
+		}
+		s = n.String()
+	}
+	return s, true
+}
+
+func (d *fieldDecoder) float(key string) float64 {
+	s, ok := d.number(key)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		d.fail(key, d.raw[key], err)
+		return 0
+	}
+	return f
+}
+
+func (d *fieldDecoder) int(key string) int64 {
+	s, ok := d.number(key)
+	if !ok {
+		return 0
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	// 1.0e8 这样的写法
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) {
+		d.fail(key, d.raw[key], errors.New("not an integer"))
+		return 0
+	}
+	return int64(f)
+}
+
+// price 定点数价格换算成元
+func (d *fieldDecoder) price(key string) float64 {
+	return d.float(key) / fixedPointScale
+}
+
+func (d *fieldDecoder) str(key string) string {
+	v, ok := d.raw[key]
+	if !ok || bytes.Equal(v, []byte("null")) {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		d.fail(key, v, errors.New("not a string"))
+	}
+	return s
+}
+
+// text 字符串原样返回，其他类型返回 JSON 原文
+func (d *fieldDecoder) text(key string) string {
+	v, ok := d.raw[key]
+	if !ok || bytes.Equal(v, []byte("null")) {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	return string(v)
+}
+
+// GetFundamentalsFromJFZT 获取 ETF 的基本面
+func GetFundamentalsFromJFZT(ctx context.Context, market string, inst string) (Fundamentals, error) {
+	link := jfztFundamentalsURL + strings.ToLower(market) + "etf" + inst
+	resp, err := HttpRequestContext(ctx, link, "GET", nil, "")
+	if err != nil {
+		return Fundamentals{}, err
+	}
+
+	var respData ResponseDataEtf
+	if err := json.Unmarshal([]byte(resp), &respData); err != nil {
+		return Fundamentals{}, fmt.Errorf("jfzt-etf %s%s: %w", market, inst, err)
+	}
+	if respData.Code != 0 {
+		return Fundamentals{}, errors.New(respData.ErrorMessage)
+	}
+	f, err := ParseFundamentals(respData.Data)
+	if err != nil {
+		return Fundamentals{}, fmt.Errorf("jfzt-etf %s%s: %w", market, inst, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFundamentalsRecorded(t *testing.T) {
+	var resp ResponseDataEtf
+	if err := json.Unmarshal([]byte(readTestdata(t, "jfzt_fundamentals_510300.json")), &resp); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFundamentals(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 价格除以 1000，不是乘以 0.001
+	prices := []struct {
+		name      string
+		got, want float64
+	}{
+		{"PreClosePx", f.PreClosePx, 3.549},
+		{"UpPx", f.UpPx, 3.904},
+		{"DownPx", f.DownPx, 3.194},
+		{"W52HighPx", f.W52HighPx, 4.267},
+		{"W52LowPx", f.W52LowPx, 3.488},
+		{"LastPx", f.LastPx, 0},
+	}
+	for _, p := range prices {
+		if p.got != p.want {
+			t.Errorf("%s = %v, want %v", p.name, p.got, p.want)
+		}
+	}
+	if f.ProdCode != "510300" || f.ProdName != "300ETF" || f.HqTypeCode != "XSHG.EM.ETF" || f.TradeStatus != "START" {
+		t.Errorf("names = %q %q %q %q", f.ProdCode, f.ProdName, f.HqTypeCode, f.TradeStatus)
+	}
+	if f.SharesPerHand != 100 || f.BidGrp != "" || f.OfferGrp != "" {
+		t.Errorf("SharesPerHand = %d, BidGrp = %q, OfferGrp = %q", f.SharesPerHand, f.BidGrp, f.OfferGrp)
+	}
+	want := time.Date(2023, 10, 25, 9, 0, 18, 370*int(time.Millisecond), ShanghaiLocation())
+	if !f.Time().Equal(want) {
+		t.Errorf("Time = %v, want %v", f.Time(), want)
+	}
+	if k := f.Kline(); k.PreClose != 3.549 || k.StockCode != "510300" || k.Time != want.Unix() {
+		t.Errorf("Kline = %+v", k)
+	}
+}
+
+func TestParseFundamentals(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		check     func(f Fundamentals) bool
+		wantField string // 出错的字段，为空时不应出错
+	}{
+		{
+			name: "prices divided by 1000",
+			data: `{"lastPx": 3612, "openPrice": 3549, "highPx": 3650, "lowPx": 3501, "pxChange": -37, "ipoPrice": 1000}`,
+			check: func(f Fundamentals) bool {
+				return f.LastPx == 3.612 && f.OpenPrice == 3.549 && f.HighPx == 3.65 && f.LowPx == 3.501 && f.PxChange == -0.037 && f.IpoPrice == 1
+			},
+		},
+		{
+			name: "numbers as strings",
+			data: `{"lastPx": "3612", "businessAmount": " 1200 ", "pxChangeRate": "1.77"}`,
+			check: func(f Fundamentals) bool {
+				return f.LastPx == 3.612 && f.BusinessAmount == 1200 && f.PxChangeRate == 1.77
+			},
+		},
+		{
+			name:  "null, empty and missing fields",
+			data:  `{"lastPx": null, "businessAmount": "", "prodCode": null}`,
+			check: func(f Fundamentals) bool { return f == (Fundamentals{}) },
+		},
+		{
+			name:  "integer in exponent form",
+			data:  `{"businessAmount": 1.2e8}`,
+			check: func(f Fundamentals) bool { return f.BusinessAmount == 120000000 },
+		},
+		{
+			name:  "order book kept as raw JSON",
+			data:  `{"bidGrp": [3611, 1000, 3], "offerGrp": "3612,500,1"}`,
+			check: func(f Fundamentals) bool { return f.BidGrp == "[3611, 1000, 3]" && f.OfferGrp == "3612,500,1" },
+		},
+		{name: "price not a number", data: `{"lastPx": true}`, wantField: "lastPx"},
+		{name: "price string not a number", data: `{"preClosePx": "3.5x"}`, wantField: "preClosePx"},
+		{name: "fraction in integer field", data: `{"businessAmount": 1.5}`, wantField: "businessAmount"},
+		{name: "date string not a number", data: `{"marketDate": "2023-10-25"}`, wantField: "marketDate"},
+		{name: "code not a string", data: `{"prodCode": 510300}`, wantField: "prodCode"},
+		{name: "first bad field reported", data: `{"lastPx": {}, "prodCode": []}`, wantField: "prodCode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFundamentals(json.RawMessage(tt.data))
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !tt.check(f) {
+					t.Errorf("got %+v", f)
+				}
+				return
+			}
+			var fe *FieldError
+			if !errors.As(err, &fe) || fe.Field != tt.wantField {
+				t.Fatalf("err = %v, want a FieldError for %s", err, tt.wantField)
+			}
+			if !strings.Contains(err.Error(), "field "+tt.wantField) {
+				t.Errorf("err = %q does not name %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestParseFundamentalsInvalid(t *testing.T) {
+	for _, data := range []string{`null`, `[1, 2]`, `{`} {
+		if _, err := ParseFundamentals(json.RawMessage(data)); err == nil {
+			t.Errorf("ParseFundamentals(%s) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Intraday 当前交易日的分时，换日后前一天的数据全部丢弃
+var Intraday = NewIntradayStore()
+
+// IntradayRefresh 同一个品种多久拉取一次分时，分时一分钟才更新一次
+var IntradayRefresh = time.Minute
+
+// IntradaySeries 某个品种一天的分时序列
+type IntradaySeries struct {
+	Symbol  string
+	Date    string // 20240517
+	Minutes []MinuteData
+}
+
+type IntradayStore struct {
+	mu      sync.RWMutex
+	date    string                  // 当前交易日，20240517
+	data    map[string][]MinuteData // symbol -> 当前交易日的分时
+	fetched map[string]time.Time    // symbol -> 上一次拿到分时的时间
+	watched map[string]bool         // 表格以外需要分时的品种，如 TUI 详情面板的当前行
+}
+
+func NewIntradayStore() *IntradayStore {
+	return &IntradayStore{
+		data:    make(map[string][]MinuteData),
+		fetched: make(map[string]time.Time),
+		watched: make(map[string]bool),
+	}
+}
+
+// Update 用最新拉取的分时替换当天的数据。
+// 比当前交易日早的数据忽略；更晚的说明已经换日，丢弃所有品种前一天的数据；同一天拉到的比已有的少时保留已有的
+func (s *IntradayStore) Update(symbol string, date string, minutes []MinuteData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case date < s.date:
+		return
+	case date > s.date:
+		s.date = date
+		s.data = make(map[string][]MinuteData)
+		s.fetched = make(map[string]time.Time)
+	}
+	s.fetched[symbol] = time.Now()
+	if len(minutes) < len(s.data[symbol]) {
+		return
+	}
+	list := make([]MinuteData, len(minutes))
+	copy(list, minutes)
+	s.data[symbol] = list
+}
+
+// Latest 当前交易日的分时
+func (s *IntradayStore) Latest(symbol string) (IntradaySeries, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	minutes, ok := s.data[symbol]
+	if !ok {
+		return IntradaySeries{}, false
+	}
+	list := make([]MinuteData, len(minutes))
+	copy(list, minutes)
+	return IntradaySeries{Symbol: symbol, Date: s.date, Minutes: list}, true
+}
+
+// Retain 只保留 symbols 中的品种，其余的分时和拉取时间都删除
+func (s *IntradayStore) Retain(symbols map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for symbol := range s.data {
+		if !symbols[symbol] {
+			delete(s.data, symbol)
+		}
+	}
+	for symbol := range s.fetched {
+		if !symbols[symbol] {
+			delete(s.fetched, symbol)
+		}
+	}
+}
+
+// Watch 设置表格以外需要分时的品种，替换上一次设置的
+func (s *IntradayStore) Watch(symbols ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watched = make(map[string]bool, len(symbols))
+	for _, v := range symbols {
+		s.watched[v] = true
+	}
+}
+
+// due 是否需要拉取：表格有 intraday 列或者被 Watch，并且距上一次拿到超过 IntradayRefresh
+func (s *IntradayStore) due(symbol string, now time.Time) bool {
+	if !ColumnShown("intraday") && !s.isWatched(symbol) {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	at, ok := s.fetched[symbol]
+	return !ok || now.Sub(at) >= IntradayRefresh
+}
+
+func (s *IntradayStore) isWatched(symbol string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.watched[symbol]
+}
+
+// FetchIntraday 需要时从腾讯拉取当天的分时写入 Intraday。
+// 只拉取显示分时的品种，同一品种 IntradayRefresh 内只拉取一次；主数据源是腾讯时报价已经带回了分时，不会再拉取
+func FetchIntraday(ctx context.Context, ins Instrument) error {
+	if ins.Type != InstrumentStock && ins.Type != InstrumentETF {
+		return nil
+	}
+	if !Intraday.due(ins.Symbol(), time.Now()) {
+		return nil
+	}
+	date, minutes, _, err := GetMinuteDataFromTencent(ctx, ins.Symbol())
+	if err != nil {
+		return err
+	}
+	if len(minutes) > 0 {
+		Intraday.Update(ins.Symbol(), date, minutes)
+	}
+	return nil
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline 把分时价格压缩成 width 个字符的走势图
+func Sparkline(minutes []MinuteData, width int) string {
+	if len(minutes) == 0 || width <= 0 {
+		return ""
+	}
+	if width > len(minutes) {
+		width = len(minutes)
+	}
+
+	// 每个字符取对应区间最后一分钟的价格
+	points := make([]float64, width)
+	for i := 0; i < width; i++ {
+		idx := (i+1)*len(minutes)/width - 1
+		points[i] = minutes[idx].Price
+	}
+
+	low, high := points[0], points[0]
+	for _, v := range points {
+		if v < low {
+			low = v
+		}
+		if v > high {
+			high = v
+		}
+	}
+
+	runes := make([]rune, width)
+	for i, v := range points {
+		level := 0
+		if high > low {
+			level = int((v - low) / (high - low) * float64(len(sparkBlocks)-1))
+		}
+		runes[i] = sparkBlocks[level]
+	}
+	return string(runes)
+}
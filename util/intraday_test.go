@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// withSettings 测试期间使用 s 作为 ActiveSettings
+func withSettings(t *testing.T, s Settings) {
+	t.Helper()
+	activeMu.Lock()
+	old := activeSettings
+	activeSettings = s
+	activeMu.Unlock()
+	t.Cleanup(func() {
+		activeMu.Lock()
+		activeSettings = old
+		activeMu.Unlock()
+	})
+}
+
+func minutes(prices ...float64) []MinuteData {
+	list := make([]MinuteData, 0, len(prices))
+	for _, p := range prices {
+		list = append(list, MinuteData{Price: p})
+	}
+	return list
+}
+
+func TestIntradayStoreKeepsOneDay(t *testing.T) {
+	s := NewIntradayStore()
+	s.Update("sz000001", "20240516", minutes(1, 2, 3))
+	s.Update("sh600000", "20240516", minutes(4))
+
+	// 同一天拉到的更少时保留已有的
+	s.Update("sz000001", "20240516", minutes(1))
+	if got, _ := s.Latest("sz000001"); len(got.Minutes) != 3 {
+		t.Errorf("shorter update replaced the series: %v", got.Minutes)
+	}
+
+	// 换日后丢弃所有品种前一天的分时
+	s.Update("sz000001", "20240517", minutes(5))
+	got, ok := s.Latest("sz000001")
+	if !ok || got.Date != "20240517" || len(got.Minutes) != 1 {
+		t.Errorf("Latest after new day = %+v, %v", got, ok)
+	}
+	if _, ok := s.Latest("sh600000"); ok {
+		t.Error("previous day of sh600000 was kept")
+	}
+
+	// 晚到的前一天数据忽略
+	s.Update("sh600000", "20240516", minutes(4))
+	if _, ok := s.Latest("sh600000"); ok {
+		t.Error("older day was stored")
+	}
+}
+
+func TestIntradayStoreDue(t *testing.T) {
+	now := time.Now()
+	noIntraday := Settings{Columns: []ColumnSpec{{Key: "code"}, {Key: "current"}}}
+
+	t.Run("not shown", func(t *testing.T) {
+		withSettings(t, noIntraday)
+		s := NewIntradayStore()
+		if s.due("sz000001", now) {
+			t.Error("due without intraday column or watch")
+		}
+		s.Watch("sz000001")
+		if !s.due("sz000001", now) || s.due("sh600000", now) {
+			t.Error("due should follow Watch")
+		}
+		s.Watch()
+		if s.due("sz000001", now) {
+			t.Error("due after Watch was cleared")
+		}
+	})
+
+	t.Run("column shown", func(t *testing.T) {
+		withSettings(t, Settings{}) // DefaultColumns 包括 intraday
+		s := NewIntradayStore()
+		if !s.due("sz000001", now) {
+			t.Error("not due with the default columns")
+		}
+		// 腾讯报价写入后一分钟内不再拉取
+		s.Update("sz000001", "20240517", minutes(1))
+		if s.due("sz000001", time.Now()) {
+			t.Error("due right after an update")
+		}
+		if !s.due("sz000001", time.Now().Add(IntradayRefresh)) {
+			t.Error("not due after IntradayRefresh")
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// BackfillPageSize 每次向九方智投请求的 K 线数量
+var BackfillPageSize = 200
+
+// KlineStore 保存 K 线，实现在 store 包
+type KlineStore interface {
+	// LatestKlineTime 已保存的最新一根 K 线的时间，没有时返回 0
+	LatestKlineTime(ctx context.Context, symbol string, period Period) (int64, error)
+	// CountKlines 已保存的时间在 [from, to] 之间的 K 线数量
+	CountKlines(ctx context.Context, symbol string, period Period, from, to int64) (int, error)
+	// UpsertKlines 在一个事务中写入 K 线，已存在的按 (symbol, period, time) 更新
+	UpsertKlines(ctx context.Context, symbol string, period Period, bars []KlineData) error
+}
+
+// BackfillKlines 分页拉取 K 线历史写入 kline 表，每个周期单独保存。
+// 从最新的一页往前翻到服务端没有更多数据为止，缺少 K 线的页逐页写入，中间缺失的部分也能补上，
+// 中断时已经写入的页不会丢。返回写入的条数
+func BackfillKlines(ctx context.Context, repo KlineStore, ins Instrument, period Period) (int, error) {
+	fetch := func(ctx context.Context, start, end int) ([]KlineData, error) {
+		var page []KlineData
+		err := DefaultTokenManager.Do(ctx, func(token string) error {
+			var err error
+			page, err = GetKlineDataFromJFZT(ctx, ins.Market, ins.Code, token, period, start, end)
+			return err
+		})
+		return page, err
+	}
+
+	n, err := backfillKlines(ctx, repo, ins.Symbol(), period, fetch)
+	if err != nil {
+		return n, fmt.Errorf("%s: %w", ins.Symbol(), err)
+	}
+	return n, nil
+}
+
+// backfillKlines fetch 返回第 start 到 end 根 K 线，0 是最新的一根
+func backfillKlines(ctx context.Context, repo KlineStore, symbol string, period Period, fetch func(ctx context.Context, start, end int) ([]KlineData, error)) (int, error) {
+	latest, err := repo.LatestKlineTime(ctx, symbol, period)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	var oldest int64
+	for start := 0; ; start += BackfillPageSize {
+		page, err := fetch(ctx, start, start+BackfillPageSize-1)
+		if err != nil {
+			return written, err
+		}
+
+		bars := make([]KlineData, 0, len(page))
+		for _, v := range page {
+			if oldest != 0 && v.Time >= oldest {
+				// 服务端忽略了分页参数，返回了重复的数据
+				continue
+			}
+			bars = append(bars, v)
+		}
+		if len(bars) == 0 {
+			break
+		}
+		sort.Slice(bars, func(i, j int) bool { return bars[i].Time < bars[j].Time })
+		oldest = bars[0].Time
+		newest := bars[len(bars)-1].Time
+
+		saved, err := repo.CountKlines(ctx, symbol, period, oldest, newest)
+		if err != nil {
+			return written, err
+		}
+		// 最新一根已保存的也更新一下，盘中保存的可能不完整
+		if saved < len(bars) || newest >= latest {
+			if err := repo.UpsertKlines(ctx, symbol, period, bars); err != nil {
+				return written, err
+			}
+			written += len(bars)
+		}
+
+		if len(page) < BackfillPageSize {
+			break
+		}
+	}
+	return written, nil
+}
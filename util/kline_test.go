@@ -0,0 +1,147 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeKlineStore 记录每次 UpsertKlines 写入的时间
+type fakeKlineStore struct {
+	bars    map[int64]KlineData
+	upserts [][]int64
+}
+
+func (s *fakeKlineStore) LatestKlineTime(ctx context.Context, symbol string, period Period) (int64, error) {
+	var latest int64
+	for t := range s.bars {
+		if t > latest {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+func (s *fakeKlineStore) CountKlines(ctx context.Context, symbol string, period Period, from, to int64) (int, error) {
+	n := 0
+	for t := range s.bars {
+		if t >= from && t <= to {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *fakeKlineStore) UpsertKlines(ctx context.Context, symbol string, period Period, bars []KlineData) error {
+	times := make([]int64, 0, len(bars))
+	for _, v := range bars {
+		s.bars[v.Time] = v
+		times = append(times, v.Time)
+	}
+	s.upserts = append(s.upserts, times)
+	return nil
+}
+
+func (s *fakeKlineStore) times() []int64 {
+	list := make([]int64, 0, len(s.bars))
+	for t := range s.bars {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// fakeKlineServer 服务端有 1..n 的 K 线，第 0 根是最新的
+func fakeKlineServer(n int, calls *int) func(ctx context.Context, start, end int) ([]KlineData, error) {
+	return func(ctx context.Context, start, end int) ([]KlineData, error) {
+		*calls++
+		page := make([]KlineData, 0)
+		for i := start; i <= end && i < n; i++ {
+			t := int64(n - i)
+			page = append(page, KlineData{TradingDay: t, Time: t, Close: float64(t)})
+		}
+		return page, nil
+	}
+}
+
+func rangeTimes(from, to int64) []int64 {
+	list := make([]int64, 0)
+	for t := from; t <= to; t++ {
+		list = append(list, t)
+	}
+	return list
+}
+
+func TestBackfillKlinesPaging(t *testing.T) {
+	defer func(size int) { BackfillPageSize = size }(BackfillPageSize)
+	BackfillPageSize = 3
+
+	t.Run("empty store", func(t *testing.T) {
+		repo := &fakeKlineStore{bars: map[int64]KlineData{}}
+		calls := 0
+		n, err := backfillKlines(context.Background(), repo, "sz000001", PeriodDay, fakeKlineServer(7, &calls))
+		if err != nil || n != 7 {
+			t.Fatalf("backfillKlines = %d, %v, want 7", n, err)
+		}
+		// 逐页写入，每页从早到晚
+		want := [][]int64{{5, 6, 7}, {2, 3, 4}, {1}}
+		if !reflect.DeepEqual(repo.upserts, want) || calls != 3 {
+			t.Errorf("upserts = %v in %d calls, want %v in 3", repo.upserts, calls, want)
+		}
+	})
+
+	t.Run("gap in the middle", func(t *testing.T) {
+		repo := &fakeKlineStore{bars: map[int64]KlineData{}}
+		for _, v := range []int64{1, 2, 3, 6, 7, 8, 9} {
+			repo.bars[v] = KlineData{Time: v}
+		}
+		calls := 0
+		n, err := backfillKlines(context.Background(), repo, "sz000001", PeriodDay, fakeKlineServer(10, &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 第一页有新的 10 和最新的 9，第二页缺 4、5，第三页已经保存过不写入
+		want := [][]int64{{8, 9, 10}, {5, 6, 7}, {2, 3, 4}}
+		if !reflect.DeepEqual(repo.upserts, want) || n != 9 {
+			t.Errorf("upserts = %v (%d bars), want %v", repo.upserts, n, want)
+		}
+		if got := repo.times(); !reflect.DeepEqual(got, rangeTimes(1, 10)) {
+			t.Errorf("saved = %v, want 1..10", got)
+		}
+	})
+
+	t.Run("server ignores paging", func(t *testing.T) {
+		repo := &fakeKlineStore{bars: map[int64]KlineData{}}
+		calls := 0
+		server := fakeKlineServer(3, &calls)
+		fetch := func(ctx context.Context, start, end int) ([]KlineData, error) {
+			return server(ctx, 0, end-start)
+		}
+		n, err := backfillKlines(context.Background(), repo, "sz000001", PeriodDay, fetch)
+		if err != nil || n != 3 || calls != 2 {
+			t.Errorf("backfillKlines = %d, %v in %d calls, want 3 bars in 2 calls", n, err, calls)
+		}
+	})
+
+	t.Run("error keeps written pages", func(t *testing.T) {
+		repo := &fakeKlineStore{bars: map[int64]KlineData{}}
+		calls := 0
+		server := fakeKlineServer(10, &calls)
+		fail := errors.New("6403")
+		fetch := func(ctx context.Context, start, end int) ([]KlineData, error) {
+			if start > 0 {
+				return nil, fail
+			}
+			return server(ctx, start, end)
+		}
+		n, err := backfillKlines(context.Background(), repo, "sz000001", PeriodDay, fetch)
+		if !errors.Is(err, fail) || n != 3 {
+			t.Errorf("backfillKlines = %d, %v, want 3 and the fetch error", n, err)
+		}
+		if got := repo.times(); !reflect.DeepEqual(got, rangeTimes(8, 10)) {
+			t.Errorf("saved = %v, want the first page", got)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// DepthLevels 盘口档数
+const DepthLevels = 5
+
+// bidGrp、offerGrp 是恒生行情的 bid_grp、offer_grp：从第一档开始，每档 价格,数量,委托笔数，末尾有逗号。
+// 一档行情没有委托笔数，固定为 0；也有行情源每档只有 价格,数量，按字段数区分，五档为 15 个或 10 个。
+// 价格和其他价格字段一样是乘以 1000 后的整数，数量单位为股，价格为 0 的档位没有挂单
+// "3548,120000,0,3547,80000,0,3546,50000,0,3545,30000,0,3544,10000,0,"
+
+// DepthLevel 一档盘口
+type DepthLevel struct {
+	Price  float64
+	Volume int64
+	Orders int64 // 委托笔数，行情源没有时为 0
+}
+
+// OrderBook 五档盘口，Bids[0] 为买一，Asks[0] 为卖一
+type OrderBook struct {
+	Bids []DepthLevel
+	Asks []DepthLevel
+	Tick float64 // 最小价格变动
+}
+
+// TickSize 最小价格变动：股票 0.01 元，ETF 0.001 元
+func TickSize(t InstrumentType) float64 {
+	if t == InstrumentETF {
+		return 0.001
+	}
+	return 0.01
+}
+
+// ParseDepth 解析 bidGrp 或 offerGrp，价格为 0 的档位表示没有挂单，跳过
+func ParseDepth(grp string) ([]DepthLevel, error) {
+	grp = strings.TrimSpace(grp)
+	if grp == "" {
+		return nil, nil
+	}
+	fields := strings.Split(strings.TrimSuffix(grp, ","), ",")
+	width := depthWidth(len(fields))
+	if width == 0 {
+		return nil, fmt.Errorf("depth %q: %d fields, want 5 or 10 levels of price,volume[,orders]", grp, len(fields))
+	}
+
+	levels := make([]DepthLevel, 0, DepthLevels)
+	for i := 0; i < len(fields) && len(levels) < DepthLevels; i += width {
+		var v [3]int64
+		for j := 0; j < width; j++ {
+			n, err := strconv.ParseInt(strings.TrimSpace(fields[i+j]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("depth %q: level %d: %w", grp, i/width+1, err)
+			}
+			v[j] = n
+		}
+		if v[0] <= 0 {
+			continue
+		}
+		levels = append(levels, DepthLevel{Price: float64(v[0]) / fixedPointScale, Volume: v[1], Orders: v[2]})
+	}
+	return levels, nil
+}
+
+// depthWidth 每档的字段数，字段数不是五档或十档时返回 0
+func depthWidth(n int) int {
+	for _, width := range []int{3, 2} {
+		if n%width == 0 && (n/width == 5 || n/width == 10) {
+			return width
+		}
+	}
+	return 0
+}
+
+// InstrumentType 按 hqTypeCode 判断品种类型，如 XSHG.EM.ETF。
+// 基本面只从 ETF 接口获取，没有 hqTypeCode 时也按 ETF
+func (f Fundamentals) InstrumentType() InstrumentType {
+	code := strings.ToUpper(f.HqTypeCode)
+	if code == "" || strings.Contains(code, "ETF") || strings.Contains(code, "LOF") {
+		return InstrumentETF
+	}
+	return InstrumentStock
+}
+
+// OrderBook 基本面中的买卖盘口，最小价格变动按品种类型
+func (f Fundamentals) OrderBook() (OrderBook, error) {
+	bids, err := ParseDepth(f.BidGrp)
+	if err != nil {
+		return OrderBook{}, fmt.Errorf("bidGrp: %w", err)
+	}
+	asks, err := ParseDepth(f.OfferGrp)
+	if err != nil {
+		return OrderBook{}, fmt.Errorf("offerGrp: %w", err)
+	}
+	return OrderBook{Bids: bids, Asks: asks, Tick: TickSize(f.InstrumentType())}, nil
+}
+
+// Empty 买卖两边都没有挂单，例如集合竞价前或者接口返回 null
+func (b OrderBook) Empty() bool {
+	return len(b.Bids) == 0 && len(b.Asks) == 0
+}
+
+// Imbalance 买卖量不平衡度 (买量-卖量)/(买量+卖量)，范围 -1 到 1，正数表示买盘强
+func (b OrderBook) Imbalance() float64 {
+	var bid, ask int64
+	for _, v := range b.Bids {
+		bid += v.Volume
+	}
+	for _, v := range b.Asks {
+		ask += v.Volume
+	}
+	if bid+ask == 0 {
+		return 0
+	}
+	return float64(bid-ask) / float64(bid+ask)
+}
+
+// SpreadTicks 卖一和买一之间相差几个最小价格变动，任一边没有挂单时返回 false
+func (b OrderBook) SpreadTicks() (int, bool) {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 || b.Tick <= 0 {
+		return 0, false
+	}
+	return int(math.Round((b.Asks[0].Price - b.Bids[0].Price) / b.Tick)), true
+}
+
+// BuildDepthPanel 显示在行情表格下方的盘口，卖五在上，买五在下
+func BuildDepthPanel(name string, book OrderBook) string {
+	t := table.NewWriter()
+	t.SetTitle(name)
+	t.AppendHeader(table.Row{"", "Price", "Lots", "Orders"})
+
+	for i := DepthLevels - 1; i >= 0; i-- {
+		t.AppendRow(depthRow(fmt.Sprintf("Ask%d", i+1), book.Asks, i))
+	}
+	t.AppendSeparator()
+	for i := 0; i < DepthLevels; i++ {
+		t.AppendRow(depthRow(fmt.Sprintf("Bid%d", i+1), book.Bids, i))
+	}
+
+	spread := "-"
+	if n, ok := book.SpreadTicks(); ok {
+		spread = fmt.Sprintf("%d ticks", n)
+	}
+	t.SetCaption("Imbalance: %.2f  Spread: %s", book.Imbalance(), spread)
+	return t.Render()
+}
+
+func depthRow(label string, levels []DepthLevel, i int) table.Row {
+	if i >= len(levels) {
+		return table.Row{label, "-", "-", "-"}
+	}
+	v := levels[i]
+	orders := "-"
+	if v.Orders > 0 {
+		orders = strconv.FormatInt(v.Orders, 10)
+	}
+	return table.Row{label, fmt.Sprintf("%.3f", v.Price), v.Volume / 100, orders} // 数量显示为手
+}
+
+// DepthPanel 在本轮结果中找到 symbol（如 sh510300）并生成盘口，没有盘口数据时返回提示
+func DepthPanel(result []KlineData, symbol string) string {
+	symbol = strings.ToLower(symbol)
+	for _, v := range result {
+		if v.Symbol != symbol {
+			continue
+		}
+		if v.Fundamentals == nil {
+			return fmt.Sprintf("%s %s: no order book from this provider", v.StockCode, v.StockName)
+		}
+		book, err := v.Fundamentals.OrderBook()
+		if err != nil {
+			return fmt.Sprintf("%s %s: %v", v.StockCode, v.StockName, err)
+		}
+		return BuildDepthPanel(v.StockCode+" "+v.StockName, book)
+	}
+	return symbol + ": not in watchlist"
+}
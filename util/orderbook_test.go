@@ -0,0 +1,170 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fundamentalsWith 录制的基本面，替换其中的字段
+func fundamentalsWith(t *testing.T, fields map[string]interface{}) Fundamentals {
+	t.Helper()
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(readTestdata(t, "jfzt_fundamentals_510300.json")), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range fields {
+		resp.Data[k] = v
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFundamentals(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		grp     string
+		want    []DepthLevel
+		wantErr string
+	}{
+		{
+			name: "price,volume,orders with trailing comma",
+			grp:  "3548,120000,0,3547,80000,0,3546,50000,0,3545,30000,0,3544,10000,0,",
+			want: []DepthLevel{{3.548, 120000, 0}, {3.547, 80000, 0}, {3.546, 50000, 0}, {3.545, 30000, 0}, {3.544, 10000, 0}},
+		},
+		{
+			name: "with order counts",
+			grp:  "10520,19400,12,10510,85800,31,10500,232300,77,10490,42100,9,10480,60700,15",
+			want: []DepthLevel{{10.52, 19400, 12}, {10.51, 85800, 31}, {10.5, 232300, 77}, {10.49, 42100, 9}, {10.48, 60700, 15}},
+		},
+		{
+			name: "price,volume pairs",
+			grp:  "3549,2000,3550,15000,3551,8000,3552,0,0,0",
+			want: []DepthLevel{{3.549, 2000, 0}, {3.55, 15000, 0}, {3.551, 8000, 0}, {3.552, 0, 0}},
+		},
+		{
+			name: "ten levels keeps five",
+			grp:  strings.Repeat("3548,100,1,", 10),
+			want: []DepthLevel{{3.548, 100, 1}, {3.548, 100, 1}, {3.548, 100, 1}, {3.548, 100, 1}, {3.548, 100, 1}},
+		},
+		{
+			name: "limit up, no asks",
+			grp:  "0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,",
+			want: []DepthLevel{},
+		},
+		{name: "empty", grp: " ", want: nil},
+		{name: "decimal price", grp: "3.548,100,0,3.547,100,0,3.546,100,0,3.545,100,0,3.544,100,0", wantErr: "level 1"},
+		{name: "bad volume", grp: "3548,x,0,3547,100,0,3546,100,0,3545,100,0,3544,100,0", wantErr: "level 1"},
+		{name: "wrong number of fields", grp: "3548,120000,0,3547,80000,0", wantErr: "6 fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDepth(tt.grp)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFundamentalsOrderBook(t *testing.T) {
+	tests := []struct {
+		name      string
+		fields    map[string]interface{}
+		tick      float64
+		spread    int
+		spreadOK  bool
+		imbalance float64
+		empty     bool
+	}{
+		{
+			name:   "recorded before the open",
+			fields: nil, // bidGrp、offerGrp 为 null
+			tick:   0.001, empty: true,
+		},
+		{
+			name: "etf",
+			fields: map[string]interface{}{
+				"bidGrp":   "3548,30000,0,3547,10000,0,3546,0,0,3545,0,0,3544,0,0,",
+				"offerGrp": "3550,10000,0,3551,10000,0,3552,0,0,3553,0,0,3554,0,0,",
+			},
+			tick: 0.001, spread: 2, spreadOK: true, imbalance: 1.0 / 3,
+		},
+		{
+			name: "stock",
+			fields: map[string]interface{}{
+				"hqTypeCode": "XSHE.ESA.SMSE",
+				"bidGrp":     "9350,1000,0,9340,1000,0,9330,1000,0,9320,1000,0,9310,1000,0,",
+				"offerGrp":   "9370,1000,0,9380,1000,0,9390,1000,0,9400,1000,0,9410,1000,0,",
+			},
+			tick: 0.01, spread: 2, spreadOK: true,
+		},
+		{
+			name: "limit up",
+			fields: map[string]interface{}{
+				"bidGrp":   "3904,500000,0,3903,1000,0,3902,1000,0,3901,1000,0,3900,1000,0,",
+				"offerGrp": "0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,",
+			},
+			tick: 0.001, imbalance: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book, err := fundamentalsWith(t, tt.fields).OrderBook()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if book.Tick != tt.tick {
+				t.Errorf("Tick = %v, want %v", book.Tick, tt.tick)
+			}
+			if book.Empty() != tt.empty {
+				t.Errorf("Empty = %v", book.Empty())
+			}
+			if n, ok := book.SpreadTicks(); n != tt.spread || ok != tt.spreadOK {
+				t.Errorf("SpreadTicks = %d, %v, want %d, %v", n, ok, tt.spread, tt.spreadOK)
+			}
+			if got := book.Imbalance(); got-tt.imbalance > 1e-9 || tt.imbalance-got > 1e-9 {
+				t.Errorf("Imbalance = %v, want %v", got, tt.imbalance)
+			}
+		})
+	}
+
+	// 字段数不对时报出是哪一边
+	f := fundamentalsWith(t, map[string]interface{}{"offerGrp": "3550,100"})
+	if _, err := f.OrderBook(); err == nil || !strings.HasPrefix(err.Error(), "offerGrp: ") {
+		t.Errorf("err = %v, want an offerGrp error", err)
+	}
+}
+
+func TestBuildDepthPanel(t *testing.T) {
+	book := OrderBook{
+		Bids: []DepthLevel{{Price: 3.548, Volume: 30000}},
+		Asks: []DepthLevel{{Price: 3.55, Volume: 10000, Orders: 4}},
+		Tick: 0.001,
+	}
+	out := BuildDepthPanel("510300 300ETF", book)
+	for _, want := range []string{"Ask1", "3.550", "100", "Bid1", "3.548", "300", "Imbalance: 0.50", "Spread: 2 ticks"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("panel does not contain %q:\n%s", want, out)
+		}
+	}
+}
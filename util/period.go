@@ -0,0 +1,179 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Period K 线周期，值为九方智投 busi 接口的 Period 参数
+type Period string
+
+const (
+	PeriodMin1  Period = "MIN1"
+	PeriodMin5  Period = "MIN5"
+	PeriodMin15 Period = "MIN15"
+	PeriodMin30 Period = "MIN30"
+	PeriodMin60 Period = "MIN60"
+	PeriodDay   Period = "DAY"
+	PeriodWeek  Period = "WEEK"
+	PeriodMonth Period = "MONTH"
+)
+
+// Periods 所有支持的周期
+var Periods = []Period{PeriodMin1, PeriodMin5, PeriodMin15, PeriodMin30, PeriodMin60, PeriodDay, PeriodWeek, PeriodMonth}
+
+// ParsePeriod 解析周期，不区分大小写，也接受 1/5/15/30/60 表示分钟
+func ParsePeriod(s string) (Period, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, p := range Periods {
+		if string(p) == s || "MIN"+s == string(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown period %q", s)
+}
+
+// Minutes 分钟周期的分钟数，日线及以上返回 0
+func (p Period) Minutes() int {
+	switch p {
+	case PeriodMin1:
+		return 1
+	case PeriodMin5:
+		return 5
+	case PeriodMin15:
+		return 15
+	case PeriodMin30:
+		return 30
+	case PeriodMin60:
+		return 60
+	}
+	return 0
+}
+
+// KlineProvider 可以按周期获取历史 K 线的数据源
+type KlineProvider interface {
+	QuoteProvider
+	// Periods 支持的周期
+	Periods() []Period
+	// Klines 最近 count 根 K 线，从早到晚
+	Klines(ctx context.Context, ins Instrument, period Period, count int) ([]KlineData, error)
+}
+
+// FetchKlines 依次尝试支持该周期的数据源
+func FetchKlines(ctx context.Context, ins Instrument, period Period, count int) ([]KlineData, error) {
+	var errs []error
+	for _, p := range Providers(ins.Type) {
+		kp, ok := p.(KlineProvider)
+		if !ok || !supportsPeriod(kp, period) {
+			continue
+		}
+		list, err := kp.Klines(ctx, ins, period, count)
+		if err == nil {
+			return list, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no provider for %s period %s", ins.Code, period)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func supportsPeriod(p KlineProvider, period Period) bool {
+	for _, v := range p.Periods() {
+		if v == period {
+			return true
+		}
+	}
+	return false
+}
+
+// periodKey 日 K 所属的周或月，如 2024-W20、2024-05
+func periodKey(tradingDay int64, period Period) string {
+	t := time.Unix(tradingDay, 0).In(ShanghaiLocation())
+	switch period {
+	case PeriodWeek:
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	case PeriodMonth:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// AggregateKlines 用日 K 合成周 K 或月 K，days 从早到晚
+func AggregateKlines(days []KlineData, period Period) ([]KlineData, error) {
+	if period != PeriodWeek && period != PeriodMonth {
+		return nil, fmt.Errorf("cannot aggregate DAY bars into %s", period)
+	}
+
+	list := make([]KlineData, 0)
+	lastKey := ""
+	for _, v := range days {
+		key := periodKey(v.TradingDay, period)
+		if key != lastKey {
+			list = append(list, v)
+			lastKey = key
+			continue
+		}
+		bar := &list[len(list)-1]
+		bar.TradingDay = v.TradingDay
+		bar.Time = v.Time
+		bar.Close = v.Close
+		if v.High > bar.High {
+			bar.High = v.High
+		}
+		if v.Low < bar.Low {
+			bar.Low = v.Low
+		}
+		bar.Volume += v.Volume
+		bar.Amount += v.Amount
+	}
+	return list, nil
+}
+
+// KlineDiff 本地合成的 K 线和服务端返回的不一致
+type KlineDiff struct {
+	Key    string // 2024-W20
+	Field  string
+	Local  float64
+	Server float64
+}
+
+// CompareKlines 按周或月对比本地合成的和服务端返回的 K 线，价格允许 0.001 的误差
+func CompareKlines(local, server []KlineData, period Period) []KlineDiff {
+	byKey := make(map[string]KlineData)
+	for _, v := range server {
+		byKey[periodKey(v.TradingDay, period)] = v
+	}
+
+	diffs := make([]KlineDiff, 0)
+	for _, l := range local {
+		key := periodKey(l.TradingDay, period)
+		s, ok := byKey[key]
+		if !ok {
+			diffs = append(diffs, KlineDiff{Key: key, Field: "missing", Local: l.Close})
+			continue
+		}
+		fields := []struct {
+			name          string
+			local, server float64
+		}{
+			{"Open", l.Open, s.Open},
+			{"High", l.High, s.High},
+			{"Low", l.Low, s.Low},
+			{"Close", l.Close, s.Close},
+			{"Volume", float64(l.Volume), float64(s.Volume)},
+		}
+		for _, f := range fields {
+			if math.Abs(f.local-f.server) > 0.001 {
+				diffs = append(diffs, KlineDiff{Key: key, Field: f.name, Local: f.local, Server: f.server})
+			}
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func dayBar(date string, open, high, low, close float64, volume int64) KlineData {
+	t, err := time.ParseInLocation("2006-01-02", date, ShanghaiLocation())
+	if err != nil {
+		panic(err)
+	}
+	return KlineData{TradingDay: t.Unix(), Time: t.Unix(), Open: open, High: high, Low: low, Close: close, Volume: volume, Amount: float64(volume) * close}
+}
+
+func TestAggregateKlines(t *testing.T) {
+	// 2024-12-30 是 2025 年第 1 周，和元旦后的两天属于同一周
+	days := []KlineData{
+		dayBar("2024-12-26", 10.0, 10.5, 9.8, 10.2, 100),
+		dayBar("2024-12-27", 10.2, 10.4, 10.0, 10.1, 200),
+		dayBar("2024-12-30", 10.1, 10.9, 10.1, 10.8, 300),
+		dayBar("2024-12-31", 10.8, 11.0, 10.6, 10.7, 400),
+		dayBar("2025-01-02", 10.7, 10.7, 9.9, 10.0, 500),
+		dayBar("2025-01-03", 10.0, 10.3, 9.7, 10.3, 600),
+	}
+	days[0].PreClose = 9.9
+
+	merge := func(first, last KlineData, high, low float64, volume int64, amount float64) KlineData {
+		first.TradingDay, first.Time, first.Close = last.TradingDay, last.Time, last.Close
+		first.High, first.Low, first.Volume, first.Amount = high, low, volume, amount
+		return first
+	}
+	tests := []struct {
+		period Period
+		want   []KlineData
+	}{
+		{PeriodWeek, []KlineData{
+			merge(days[0], days[1], 10.5, 9.8, 300, 100*10.2+200*10.1),
+			merge(days[2], days[5], 11.0, 9.7, 1800, 300*10.8+400*10.7+500*10.0+600*10.3),
+		}},
+		{PeriodMonth, []KlineData{
+			merge(days[0], days[3], 11.0, 9.8, 1000, 100*10.2+200*10.1+300*10.8+400*10.7),
+			merge(days[4], days[5], 10.7, 9.7, 1100, 500*10.0+600*10.3),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.period), func(t *testing.T) {
+			got, err := AggregateKlines(days, tt.period)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+			if diffs := CompareKlines(got, tt.want, tt.period); len(diffs) != 0 {
+				t.Errorf("CompareKlines with itself = %+v", diffs)
+			}
+		})
+	}
+
+	if _, err := AggregateKlines(days, PeriodDay); err == nil {
+		t.Error("aggregating into DAY succeeded")
+	}
+	// 不修改传入的日 K
+	if days[0].Close != 10.2 || days[2].Volume != 300 {
+		t.Errorf("days modified: %+v", days)
+	}
+}
+
+func TestCompareKlines(t *testing.T) {
+	local := []KlineData{dayBar("2024-05-13", 9.3, 9.5, 9.2, 9.4, 1000), dayBar("2024-05-20", 9.4, 9.6, 9.3, 9.5, 1200)}
+	server := []KlineData{dayBar("2024-05-17", 9.3, 9.5, 9.2, 9.41, 1000)}
+	got := CompareKlines(local, server, PeriodWeek)
+	want := []KlineDiff{
+		{Key: "2024-W20", Field: "Close", Local: 9.4, Server: 9.41},
+		{Key: "2024-W21", Field: "missing", Local: 9.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
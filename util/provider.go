@@ -0,0 +1,223 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InstrumentType 品种类型，沿用 code.txt 第四个字段的值：1 股票，2 ETF
+type InstrumentType string
+
+const (
+	InstrumentStock InstrumentType = "1"
+	InstrumentETF   InstrumentType = "2"
+)
+
+// Instrument 自选列表中的一项
+type Instrument struct {
+	Market   string // SZ / SH
+	Code     string
+	Name     string
+	Type     InstrumentType
+	Group    string
+	Notes    string
+	Disabled bool
+}
+
+// Symbol 形如 sz002139，新浪和腾讯的接口都用这种写法
+func (ins Instrument) Symbol() string {
+	return strings.ToLower(ins.Market) + ins.Code
+}
+
+// ParseInstrumentType 解析配置中的类型，stock/etf，兼容 code.txt 中的 1/2
+func ParseInstrumentType(s string) (InstrumentType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "stock", "1":
+		return InstrumentStock, nil
+	case "etf", "2":
+		return InstrumentETF, nil
+	}
+	return "", fmt.Errorf("unknown instrument type %q, want stock or etf", s)
+}
+
+func (t InstrumentType) String() string {
+	switch t {
+	case InstrumentStock:
+		return "stock"
+	case InstrumentETF:
+		return "etf"
+	}
+	return string(t)
+}
+
+// ProviderHealth 数据源最近的请求情况
+type ProviderHealth struct {
+	Healthy     bool
+	Failures    int // 连续失败次数
+	LastError   error
+	LastSuccess time.Time
+	LastFailure time.Time
+}
+
+// QuoteProvider 行情数据源
+type QuoteProvider interface {
+	Name() string
+	// Supports 是否支持该类型的品种
+	Supports(t InstrumentType) bool
+	// Quote 获取最新报价
+	Quote(ctx context.Context, ins Instrument) (KlineData, error)
+	Health() ProviderHealth
+}
+
+// healthTracker 嵌入到各个数据源中记录健康状况
+type healthTracker struct {
+	mu     sync.Mutex
+	health ProviderHealth
+}
+
+func (h *healthTracker) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.health.Failures++
+		h.health.LastError = err
+		h.health.LastFailure = time.Now()
+	} else {
+		h.health.Failures = 0
+		h.health.LastError = nil
+		h.health.LastSuccess = time.Now()
+	}
+	h.health.Healthy = h.health.Failures < 3
+}
+
+func (h *healthTracker) Health() ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := h.health
+	if health.LastSuccess.IsZero() && health.LastFailure.IsZero() {
+		// 还没有请求过
+		health.Healthy = true
+	}
+	return health
+}
+
+type registeredProvider struct {
+	priority int
+	provider QuoteProvider
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   []registeredProvider
+	enabled     []string // 配置中启用的数据源，为空时全部启用
+)
+
+// RegisterProvider 注册数据源，priority 越小越优先，一般在 init 中调用
+func RegisterProvider(priority int, p QuoteProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	for _, v := range providers {
+		if v.provider.Name() == p.Name() {
+			panic("util: provider registered twice: " + p.Name())
+		}
+	}
+	providers = append(providers, registeredProvider{priority: priority, provider: p})
+	sort.SliceStable(providers, func(i, j int) bool {
+		return providers[i].priority < providers[j].priority
+	})
+}
+
+// UseProviders 只启用 names 中的数据源，并按 names 的顺序使用。names 为空时恢复为全部
+func UseProviders(names []string) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if err := checkProvidersLocked(names); err != nil {
+		return err
+	}
+	enabled = append([]string(nil), names...)
+	return nil
+}
+
+// checkProviders 检查数据源是否都已注册，不改变当前的设置
+func checkProviders(names []string) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	return checkProvidersLocked(names)
+}
+
+func checkProvidersLocked(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, v := range providers {
+			if v.provider.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown provider %q", name)
+		}
+	}
+	return nil
+}
+
+// Providers 返回支持该类型的数据源，按配置的顺序，没有配置时按优先级
+func Providers(t InstrumentType) []QuoteProvider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	list := make([]QuoteProvider, 0)
+	if len(enabled) > 0 {
+		for _, name := range enabled {
+			for _, v := range providers {
+				if v.provider.Name() == name && v.provider.Supports(t) {
+					list = append(list, v.provider)
+				}
+			}
+		}
+		return list
+	}
+	for _, v := range providers {
+		if v.provider.Supports(t) {
+			list = append(list, v.provider)
+		}
+	}
+	return list
+}
+
+// GetProvider 按名称查找数据源
+func GetProvider(name string) QuoteProvider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	for _, v := range providers {
+		if v.provider.Name() == name {
+			return v.provider
+		}
+	}
+	return nil
+}
+
+// FetchQuote 依次尝试支持该品种的数据源，直到有一个成功
+func FetchQuote(ctx context.Context, ins Instrument) (KlineData, error) {
+	list := Providers(ins.Type)
+	if len(list) == 0 {
+		return KlineData{}, fmt.Errorf("no provider for %s (type %q)", ins.Code, ins.Type)
+	}
+
+	var errs []error
+	for _, p := range list {
+		data, err := p.Quote(ctx, ins)
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return KlineData{}, errors.Join(errs...)
+}
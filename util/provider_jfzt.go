@@ -0,0 +1,69 @@
+package util
+
+import (
+	"context"
+	"sort"
+)
+
+var (
+	jfztStock = &jfztStockProvider{tokens: DefaultTokenManager}
+	jfztEtf   = &jfztEtfProvider{}
+)
+
+func init() {
+	RegisterProvider(10, jfztStock)
+	RegisterProvider(10, jfztEtf)
+}
+
+// jfztStockProvider 九方智投股票 K 线，需要 token
+type jfztStockProvider struct {
+	healthTracker
+	tokens *TokenManager
+}
+
+func (p *jfztStockProvider) Name() string { return "jfzt-stock" }
+
+func (p *jfztStockProvider) Supports(t InstrumentType) bool { return t == InstrumentStock }
+
+func (p *jfztStockProvider) Quote(ctx context.Context, ins Instrument) (KlineData, error) {
+	var data KlineData
+	err := p.tokens.Do(ctx, func(token string) error {
+		var err error
+		data, err = GetStockDataFromJFZTContext(ctx, ins.Market, ins.Code, token)
+		return err
+	})
+	p.record(err)
+	return data, err
+}
+
+func (p *jfztStockProvider) Periods() []Period { return Periods }
+
+func (p *jfztStockProvider) Klines(ctx context.Context, ins Instrument, period Period, count int) ([]KlineData, error) {
+	var list []KlineData
+	err := p.tokens.Do(ctx, func(token string) error {
+		var err error
+		list, err = GetKlineDataFromJFZT(ctx, ins.Market, ins.Code, token, period, 0, count-1)
+		return err
+	})
+	p.record(err)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time < list[j].Time })
+	return list, nil
+}
+
+// jfztEtfProvider 九方智投 ETF 基本面接口，不需要 token
+type jfztEtfProvider struct {
+	healthTracker
+}
+
+func (p *jfztEtfProvider) Name() string { return "jfzt-etf" }
+
+func (p *jfztEtfProvider) Supports(t InstrumentType) bool { return t == InstrumentETF }
+
+func (p *jfztEtfProvider) Quote(ctx context.Context, ins Instrument) (KlineData, error) {
+	data, err := GetEtfDataFromJFZTContext(ctx, ins.Market, ins.Code)
+	p.record(err)
+	return data, err
+}
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// 新浪财经 K 线，不需要 token，最小 5 分钟
+// http://money.finance.sina.com.cn/quotes_service/api/json_v2.php/CN_MarketData.getKLineData?symbol=sz002139&scale=5&ma=5&datalen=10
+/*
+[
+    {
+        "day": "2024-05-17 15:00:00",
+        "open": "9.350",
+        "high": "9.370",
+        "low": "9.330",
+        "close": "9.360",
+        "volume": "3051900",
+        "ma_price5": 9.348,
+        "ma_volume5": 3476840
+    }
+]
+*/
+// 老版本返回的 key 没有引号：[{day:"2024-05-17 15:00:00",open:"9.350",...}]
+
+const sinaKlineURL = "http://money.finance.sina.com.cn/quotes_service/api/json_v2.php/CN_MarketData.getKLineData"
+
+// SinaMaxDatalen 新浪一次最多返回的条数
+const SinaMaxDatalen = 242
+
+// 新浪一天 48 根 5 分钟 K 线，取两天才能算出昨收
+const sinaQuoteDatalen = 96
+
+var sinaScales = []int{5, 15, 30, 60}
+
+var (
+	sinaBareKey  = regexp.MustCompile(`([{,]\s*)([A-Za-z_][A-Za-z0-9_]*)(\s*:)`)
+	sinaMaPrice  = regexp.MustCompile(`^ma_price(\d+)$`)
+	sinaMaVolume = regexp.MustCompile(`^ma_volume(\d+)$`)
+)
+
+// sinaNumber 新浪的数字有时带引号有时不带
+type sinaNumber float64
+
+func (n *sinaNumber) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" || s == "--" {
+		*n = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*n = sinaNumber(f)
+	return nil
+}
+
+// GetKlineDataFromSina 从新浪获取分钟 K 线
+// symbol: sz002139；scale: 5/15/30/60；ma: 均线周期，如 5,10,20；datalen: 最大 242
+func GetKlineDataFromSina(ctx context.Context, symbol string, scale int, ma []int, datalen int) ([]KlineData, error) {
+	if !validSinaScale(scale) {
+		return nil, fmt.Errorf("sina: unsupported scale %d", scale)
+	}
+	if datalen <= 0 || datalen > SinaMaxDatalen {
+		datalen = SinaMaxDatalen
+	}
+
+	mas := make([]string, 0, len(ma))
+	for _, v := range ma {
+		mas = append(mas, strconv.Itoa(v))
+	}
+	link := fmt.Sprintf("%s?symbol=%s&scale=%d&ma=%s&datalen=%d", sinaKlineURL, symbol, scale, strings.Join(mas, ","), datalen)
+	if len(mas) == 0 {
+		link = fmt.Sprintf("%s?symbol=%s&scale=%d&ma=no&datalen=%d", sinaKlineURL, symbol, scale, datalen)
+	}
+
+	resp, err := HttpRequestContext(ctx, link, "GET", nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSinaKline(resp)
+}
+
+// ParseSinaKline 解析新浪 K 线返回值，兼容 GBK 编码和 key 不带引号的写法
+func ParseSinaKline(resp string) ([]KlineData, error) {
+	body, err := decodeGBK([]byte(resp))
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || string(body) == "null" {
+		return nil, errors.New("sina: empty response")
+	}
+	body = sinaBareKey.ReplaceAll(body, []byte(`$1"$2"$3`))
+
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("sina: %w", err)
+	}
+
+	loc := ShanghaiLocation()
+	list := make([]KlineData, 0, len(rows))
+	for _, row := range rows {
+		var day string
+		if err := json.Unmarshal(row["day"], &day); err != nil {
+			return nil, fmt.Errorf("sina: invalid day: %w", err)
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", day, loc)
+		if err != nil {
+			// 日线只有日期
+			t, err = time.ParseInLocation("2006-01-02", day, loc)
+			if err != nil {
+				return nil, fmt.Errorf("sina: invalid day %q", day)
+			}
+		}
+		y, m, d := t.Date()
+
+		var open, high, low, closePx, volume sinaNumber
+		fields := map[string]*sinaNumber{"open": &open, "high": &high, "low": &low, "close": &closePx, "volume": &volume}
+		for name, dst := range fields {
+			if raw, ok := row[name]; ok {
+				if err := json.Unmarshal(raw, dst); err != nil {
+					return nil, fmt.Errorf("sina: invalid %s: %w", name, err)
+				}
+			}
+		}
+
+		data := KlineData{
+			TradingDay: time.Date(y, m, d, 0, 0, 0, 0, loc).Unix(),
+			Time:       t.Unix(),
+			Open:       float64(open),
+			High:       float64(high),
+			Low:        float64(low),
+			Close:      float64(closePx),
+			Volume:     int64(volume),
+		}
+
+		for key, raw := range row {
+			var n sinaNumber
+			if m := sinaMaPrice.FindStringSubmatch(key); m != nil {
+				if json.Unmarshal(raw, &n) == nil {
+					if data.MAPrice == nil {
+						data.MAPrice = make(map[int]float64)
+					}
+					p, _ := strconv.Atoi(m[1])
+					data.MAPrice[p] = float64(n)
+				}
+			} else if m := sinaMaVolume.FindStringSubmatch(key); m != nil {
+				if json.Unmarshal(raw, &n) == nil {
+					if data.MAVolume == nil {
+						data.MAVolume = make(map[int]float64)
+					}
+					p, _ := strconv.Atoi(m[1])
+					data.MAVolume[p] = float64(n)
+				}
+			}
+		}
+
+		list = append(list, data)
+	}
+
+	return list, nil
+}
+
+// decodeGBK 不是合法 UTF-8 时按 GBK 转码
+func decodeGBK(b []byte) ([]byte, error) {
+	if utf8.Valid(b) {
+		return b, nil
+	}
+	r := transform.NewReader(bytes.NewReader(b), simplifiedchinese.GBK.NewDecoder())
+	return io.ReadAll(r)
+}
+
+func validSinaScale(scale int) bool {
+	for _, v := range sinaScales {
+		if v == scale {
+			return true
+		}
+	}
+	return false
+}
+
+// sinaProvider 九方智投 token 过期时的备用数据源
+type sinaProvider struct {
+	healthTracker
+}
+
+func init() {
+	RegisterProvider(30, &sinaProvider{})
+}
+
+func (p *sinaProvider) Name() string { return "sina" }
+
+func (p *sinaProvider) Supports(t InstrumentType) bool {
+	return t == InstrumentStock || t == InstrumentETF
+}
+
+// Quote 用最近两天的 5 分钟 K 线拼出当天的开高低收和昨收
+func (p *sinaProvider) Quote(ctx context.Context, ins Instrument) (KlineData, error) {
+	bars, err := GetKlineDataFromSina(ctx, ins.Symbol(), 5, nil, sinaQuoteDatalen)
+	if err == nil && len(bars) == 0 {
+		err = errors.New("sina: no data")
+	}
+	p.record(err)
+	if err != nil {
+		return KlineData{}, err
+	}
+
+	return mergeIntradayBars(bars), nil
+}
+
+func (p *sinaProvider) Periods() []Period {
+	return []Period{PeriodMin5, PeriodMin15, PeriodMin30, PeriodMin60}
+}
+
+func (p *sinaProvider) Klines(ctx context.Context, ins Instrument, period Period, count int) ([]KlineData, error) {
+	list, err := GetKlineDataFromSina(ctx, ins.Symbol(), period.Minutes(), []int{5, 10, 20}, count)
+	p.record(err)
+	return list, err
+}
+
+// mergeIntradayBars 把最后一个交易日的分钟 K 线合并成一根日 K，昨收取前一交易日最后一根的收盘价
+func mergeIntradayBars(bars []KlineData) KlineData {
+	last := bars[len(bars)-1]
+	day := KlineData{
+		TradingDay: last.TradingDay,
+		Time:       last.Time,
+		Close:      last.Close,
+	}
+
+	for i, v := range bars {
+		if v.TradingDay != last.TradingDay {
+			day.PreClose = v.Close
+			continue
+		}
+		if day.Open == 0 {
+			day.Open = v.Open
+			if i == 0 {
+				day.PreClose = v.Open
+			}
+		}
+		if v.High > day.High {
+			day.High = v.High
+		}
+		if day.Low == 0 || v.Low < day.Low {
+			day.Low = v.Low
+		}
+		day.Volume += v.Volume
+	}
+
+	return day
+}
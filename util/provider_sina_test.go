@@ -0,0 +1,146 @@
+package util
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func sinaTime(t *testing.T, s string) (day, at int64) {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04:05", s, ShanghaiLocation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, m, d := v.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, ShanghaiLocation()).Unix(), v.Unix()
+}
+
+func sinaBar(t *testing.T, s string, open, high, low, close float64, volume int64) KlineData {
+	day, at := sinaTime(t, s)
+	return KlineData{TradingDay: day, Time: at, Open: open, High: high, Low: low, Close: close, Volume: volume}
+}
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestParseSinaKline(t *testing.T) {
+	withMA := func(v KlineData, price, volume float64) KlineData {
+		v.MAPrice = map[int]float64{5: price}
+		v.MAVolume = map[int]float64{5: volume}
+		return v
+	}
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(`[{day:"2024-05-17 09:35:00",name:"平安银行",open:"9.350",high:"9.420",low:"9.330",close:"9.400",volume:"6102400"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []KlineData
+		wantErr string
+	}{
+		{
+			name: "quoted keys with ma",
+			body: readTestdata(t, "sina_kline_min5.json"),
+			want: []KlineData{
+				withMA(sinaBar(t, "2024-05-16 14:55:00", 9.32, 9.34, 9.31, 9.33, 2154300), 9.318, 2413620),
+				withMA(sinaBar(t, "2024-05-16 15:00:00", 9.33, 9.35, 9.33, 9.34, 4378800), 9.326, 2871040),
+				withMA(sinaBar(t, "2024-05-17 09:35:00", 9.35, 9.42, 9.33, 9.40, 6102400), 9.352, 3715060),
+				withMA(sinaBar(t, "2024-05-17 09:40:00", 9.40, 9.41, 9.36, 9.37, 3051900), 9.366, 3822840),
+			},
+		},
+		{
+			name: "bare keys",
+			body: readTestdata(t, "sina_kline_bare.txt"),
+			want: []KlineData{
+				sinaBar(t, "2024-05-17 09:35:00", 9.35, 9.42, 9.33, 9.40, 6102400),
+				sinaBar(t, "2024-05-17 09:40:00", 9.40, 9.41, 9.36, 9.37, 0),
+			},
+		},
+		{
+			name: "gbk",
+			body: gbk,
+			want: []KlineData{sinaBar(t, "2024-05-17 09:35:00", 9.35, 9.42, 9.33, 9.40, 6102400)},
+		},
+		{
+			name: "empty array",
+			body: "[]",
+			want: []KlineData{},
+		},
+		{name: "null", body: readTestdata(t, "sina_kline_null.txt"), wantErr: "sina: empty response"},
+		{name: "empty", body: "", wantErr: "sina: empty response"},
+		{name: "error page", body: "<html><head><title>502 Bad Gateway</title></head></html>", wantErr: "sina: invalid character"},
+		{name: "bad day", body: `[{day:"2024/05/17",open:"9.35"}]`, wantErr: `sina: invalid day "2024/05/17"`},
+		{name: "bad number", body: `[{day:"2024-05-17",open:"abc"}]`, wantErr: "sina: invalid open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSinaKline(tt.body)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSinaKlineDailyDay(t *testing.T) {
+	got, err := ParseSinaKline(`[{day:"2024-05-17",open:"9.35",high:"9.42",low:"9.33",close:"9.37",volume:"91543000"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, _ := sinaTime(t, "2024-05-17 00:00:00")
+	if len(got) != 1 || got[0].TradingDay != day || got[0].Time != day {
+		t.Errorf("got %+v, want one bar on 2024-05-17", got)
+	}
+}
+
+func TestDecodeGBK(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("平安银行")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ in, want string }{
+		{gbk, "平安银行"},
+		{"平安银行", "平安银行"}, // 已经是 UTF-8 的不转换
+		{"", ""},
+	} {
+		got, err := decodeGBK([]byte(tt.in))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("decodeGBK(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestMergeIntradayBars(t *testing.T) {
+	bars, err := ParseSinaKline(readTestdata(t, "sina_kline_min5.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := mergeIntradayBars(bars)
+	day, at := sinaTime(t, "2024-05-17 09:40:00")
+	want := KlineData{TradingDay: day, Time: at, Open: 9.35, High: 9.42, Low: 9.33, Close: 9.37, PreClose: 9.34, Volume: 9154300}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 腾讯分时，唯一能拿到 1 分钟数据的接口，不需要 token
+// https://web.ifzq.gtimg.cn/appstock/app/minute/query?code=sz002139
+/*
+{
+    "code": 0,
+    "msg": "",
+    "data": {
+        "sz002139": {
+            "data": {
+                "data": [
+                    "0930 9.35 1234 1153850.00",
+                    "0931 9.36 2468 2308936.00"
+                ],
+                "date": "20240517"
+            },
+            "qt": {
+                "sz002139": ["51", "拓邦股份", "002139", "9.36", "9.35", "9.34", ...]
+            }
+        }
+    }
+}
+*/
+// data 每一项为：时间 价格 累计成交量(手) 累计成交额(元)
+// qt 下标：3 现价，4 昨收，5 今开，33 最高，34 最低
+
+const tencentMinuteURL = "https://web.ifzq.gtimg.cn/appstock/app/minute/query?code="
+
+type ResponseDataTencent struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data map[string]struct {
+		Data struct {
+			Data []string `json:"data"`
+			Date string   `json:"date"`
+		} `json:"data"`
+		Qt map[string]json.RawMessage `json:"qt"`
+	} `json:"data"`
+}
+
+// MinuteData 一分钟的分时数据
+type MinuteData struct {
+	Time   time.Time
+	Price  float64
+	Volume int64   // 累计成交量（股）
+	Amount float64 // 累计成交额（元）
+}
+
+// GetMinuteDataFromTencent 获取当天的分时数据，返回日期、分时序列，以及 qt 中的实时报价
+func GetMinuteDataFromTencent(ctx context.Context, symbol string) (string, []MinuteData, KlineData, error) {
+	resp, err := HttpRequestContext(ctx, tencentMinuteURL+symbol, "GET", nil, "")
+	if err != nil {
+		return "", nil, KlineData{}, err
+	}
+	return ParseTencentMinute(symbol, resp)
+}
+
+// ParseTencentMinute 解析腾讯分时接口的返回值
+func ParseTencentMinute(symbol string, resp string) (string, []MinuteData, KlineData, error) {
+	var respData ResponseDataTencent
+	if err := json.Unmarshal([]byte(resp), &respData); err != nil {
+		return "", nil, KlineData{}, fmt.Errorf("tencent: %w", err)
+	}
+	if respData.Code != 0 {
+		return "", nil, KlineData{}, errors.New("tencent: " + respData.Msg)
+	}
+	item, ok := respData.Data[symbol]
+	if !ok {
+		return "", nil, KlineData{}, fmt.Errorf("tencent: no data for %s", symbol)
+	}
+
+	loc := ShanghaiLocation()
+	date := item.Data.Date
+	day, err := time.ParseInLocation("20060102", date, loc)
+	if err != nil {
+		return "", nil, KlineData{}, fmt.Errorf("tencent: invalid date %q", date)
+	}
+
+	minutes := make([]MinuteData, 0, len(item.Data.Data))
+	for _, line := range item.Data.Data {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields[0]) != 4 {
+			return "", nil, KlineData{}, fmt.Errorf("tencent: invalid minute line %q", line)
+		}
+		hour, err1 := strconv.Atoi(fields[0][:2])
+		minute, err2 := strconv.Atoi(fields[0][2:])
+		price, err3 := strconv.ParseFloat(fields[1], 64)
+		volume, err4 := strconv.ParseInt(fields[2], 10, 64)
+		amount, err5 := strconv.ParseFloat(fields[3], 64)
+		if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+			return "", nil, KlineData{}, fmt.Errorf("tencent: invalid minute line %q: %w", line, err)
+		}
+		minutes = append(minutes, MinuteData{
+			Time:   day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute),
+			Price:  price,
+			Volume: volume * 100,
+			Amount: amount,
+		})
+	}
+
+	quote := quoteFromMinutes(minutes)
+	quote.TradingDay = day.Unix()
+
+	var qt []string
+	if raw, ok := item.Qt[symbol]; ok && json.Unmarshal(raw, &qt) == nil && len(qt) > 34 {
+		parse := func(i int) float64 {
+			f, _ := strconv.ParseFloat(qt[i], 64)
+			return f
+		}
+		if v := parse(3); v > 0 {
+			quote.Close = v
+		}
+		quote.PreClose = parse(4)
+		if v := parse(5); v > 0 {
+			quote.Open = v
+		}
+		if v := parse(33); v > 0 {
+			quote.High = v
+		}
+		if v := parse(34); v > 0 {
+			quote.Low = v
+		}
+	}
+
+	return date, minutes, quote, nil
+}
+
+// quoteFromMinutes 没有 qt 时用分时数据算出开高低收
+func quoteFromMinutes(minutes []MinuteData) KlineData {
+	var quote KlineData
+	if len(minutes) == 0 {
+		return quote
+	}
+	last := minutes[len(minutes)-1]
+	quote.Time = last.Time.Unix()
+	quote.Open = minutes[0].Price
+	quote.Close = last.Price
+	quote.Volume = last.Volume
+	quote.Amount = last.Amount
+	for _, v := range minutes {
+		if v.Price > quote.High {
+			quote.High = v.Price
+		}
+		if quote.Low == 0 || v.Price < quote.Low {
+			quote.Low = v.Price
+		}
+	}
+	return quote
+}
+
+// tencentProvider 腾讯分时，作为备用数据源。拿到的分时写入 Intraday，这一轮 FetchIntraday 不会再请求
+type tencentProvider struct {
+	healthTracker
+}
+
+func init() {
+	RegisterProvider(20, &tencentProvider{})
+}
+
+func (p *tencentProvider) Name() string { return "tencent" }
+
+func (p *tencentProvider) Supports(t InstrumentType) bool {
+	return t == InstrumentStock || t == InstrumentETF
+}
+
+func (p *tencentProvider) Quote(ctx context.Context, ins Instrument) (KlineData, error) {
+	date, minutes, quote, err := GetMinuteDataFromTencent(ctx, ins.Symbol())
+	if err == nil && len(minutes) == 0 {
+		err = errors.New("tencent: no minute data")
+	}
+	p.record(err)
+	if err != nil {
+		return KlineData{}, err
+	}
+
+	Intraday.Update(ins.Symbol(), date, minutes)
+	return quote, nil
+}
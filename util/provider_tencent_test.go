@@ -0,0 +1,87 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTencentMinute(t *testing.T) {
+	day := time.Date(2024, 5, 17, 0, 0, 0, 0, ShanghaiLocation())
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	date, minutes, quote, err := ParseTencentMinute("sz002139", readTestdata(t, "tencent_minute_sz002139.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date != "20240517" {
+		t.Errorf("date = %q, want 20240517", date)
+	}
+	wantMinutes := []MinuteData{
+		{Time: at(9, 30), Price: 9.35, Volume: 312000, Amount: 2917200},
+		{Time: at(9, 31), Price: 9.38, Volume: 576400, Amount: 5395290},
+		{Time: at(9, 32), Price: 9.42, Volume: 910500, Amount: 8542360},
+		{Time: at(9, 33), Price: 9.33, Volume: 1203400, Amount: 11277430},
+		{Time: at(11, 30), Price: 9.37, Volume: 1532700, Amount: 14352688.73},
+	}
+	if !reflect.DeepEqual(minutes, wantMinutes) {
+		t.Errorf("minutes = %+v\nwant %+v", minutes, wantMinutes)
+	}
+	// 开高低收和昨收来自 qt，成交量和成交额来自最后一分钟
+	wantQuote := KlineData{
+		TradingDay: day.Unix(),
+		Time:       at(11, 30).Unix(),
+		Open:       9.35,
+		High:       9.42,
+		Low:        9.33,
+		Close:      9.37,
+		PreClose:   9.34,
+		Volume:     1532700,
+		Amount:     14352688.73,
+	}
+	if !reflect.DeepEqual(quote, wantQuote) {
+		t.Errorf("quote = %+v\nwant %+v", quote, wantQuote)
+	}
+}
+
+func TestParseTencentMinuteEmptyData(t *testing.T) {
+	// 开盘前 data 为空，只有 qt
+	resp := `{"code":0,"msg":"","data":{"sz002139":{"data":{"data":[],"date":"20240517"},"qt":{"sz002139":["51","拓邦股份","002139","9.34","9.34","0.00","0","0","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","0.00","0","","20240517091000","0.00","0.00","0.00","0.00"]}}}}`
+	date, minutes, quote, err := ParseTencentMinute("sz002139", resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date != "20240517" || len(minutes) != 0 {
+		t.Errorf("date = %q, minutes = %v, want 20240517 and no minutes", date, minutes)
+	}
+	// qt 中为 0 的开高低不覆盖
+	want := KlineData{TradingDay: time.Date(2024, 5, 17, 0, 0, 0, 0, ShanghaiLocation()).Unix(), Close: 9.34, PreClose: 9.34}
+	if !reflect.DeepEqual(quote, want) {
+		t.Errorf("quote = %+v, want %+v", quote, want)
+	}
+}
+
+func TestParseTencentMinuteErrors(t *testing.T) {
+	tests := []struct {
+		name, symbol, resp, wantErr string
+	}{
+		{"unknown symbol", "sz999999", readTestdata(t, "tencent_minute_sz002139.json"), "tencent: no data for sz999999"},
+		{"empty data object", "sz002139", `{"code":0,"msg":"","data":{}}`, "tencent: no data for sz002139"},
+		{"error code", "sz002139", `{"code":-1,"msg":"param error","data":null}`, "tencent: param error"},
+		{"no date", "sz002139", `{"code":0,"msg":"","data":{"sz002139":{"data":{"data":[],"date":""},"qt":{}}}}`, `tencent: invalid date ""`},
+		{"bad line", "sz002139", `{"code":0,"msg":"","data":{"sz002139":{"data":{"data":["0930 9.35"],"date":"20240517"}}}}`, `tencent: invalid minute line "0930 9.35"`},
+		{"bad price", "sz002139", `{"code":0,"msg":"","data":{"sz002139":{"data":{"data":["0930 x 1 2"],"date":"20240517"}}}}`, `tencent: invalid minute line "0930 x 1 2"`},
+		{"not json", "sz002139", "v_pv_none_match=\"1\";", "tencent: invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := ParseTencentMinute(tt.symbol, tt.resp)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
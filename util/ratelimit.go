@@ -0,0 +1,159 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// RateLimit 每秒 Rate 个请求，最多攒 Burst 个
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// TokenBucket 令牌桶
+type TokenBucket struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &TokenBucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// Wait 阻塞直到拿到一个令牌，ctx 取消时返回错误并归还预占的令牌
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.limit.Rate <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+	// 先预占，令牌不够时 tokens 为负，后来的请求排在后面
+	b.tokens--
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// HostLimiter 按 host 限流，没有配置的 host 不限
+type HostLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*TokenBucket
+}
+
+// DefaultRateLimits 内置的限流，配置文件中的 rate_limits 按 host 覆盖
+var DefaultRateLimits = map[string]RateLimit{
+	"qas.sylapp.cn":             {Rate: 3, Burst: 5},
+	"hq.chongnengjihua.com":     {Rate: 3, Burst: 5},
+	"stock.9fzt.com":            {Rate: 1, Burst: 2},
+	"web.ifzq.gtimg.cn":         {Rate: 5, Burst: 10}, // 腾讯报价和分时，每个品种一次
+	"money.finance.sina.com.cn": {Rate: 2, Burst: 4},  // 新浪 K 线，回填时连续翻页
+}
+
+// DefaultHostLimiter 所有对外请求共用，Config.Apply 时替换为 DefaultRateLimits 加上配置文件中的 rate_limits
+var DefaultHostLimiter = NewHostLimiter(DefaultRateLimits)
+
+func NewHostLimiter(limits map[string]RateLimit) *HostLimiter {
+	h := &HostLimiter{buckets: make(map[string]*TokenBucket)}
+	for host, limit := range limits {
+		h.Set(host, limit)
+	}
+	return h
+}
+
+// Set 设置 host 的限流，和原来的一样时保留已有的令牌桶
+func (h *HostLimiter) Set(host string, limit RateLimit) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if b, ok := h.buckets[host]; ok && b.limit == limit {
+		return
+	}
+	h.buckets[host] = NewTokenBucket(limit)
+}
+
+// Replace 把限流表替换为 DefaultRateLimits 加上 limits，limits 中没有的 host 恢复默认或不再限流。
+// 限流没有变化的 host 保留已有的令牌桶
+func (h *HostLimiter) Replace(limits map[string]RateLimit) {
+	merged := make(map[string]RateLimit, len(DefaultRateLimits)+len(limits))
+	for host, limit := range DefaultRateLimits {
+		merged[host] = limit
+	}
+	for host, limit := range limits {
+		merged[host] = limit
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets := make(map[string]*TokenBucket, len(merged))
+	for host, limit := range merged {
+		if b, ok := h.buckets[host]; ok && b.limit == limit {
+			buckets[host] = b
+		} else {
+			buckets[host] = NewTokenBucket(limit)
+		}
+	}
+	h.buckets = buckets
+}
+
+// Wait 等待 host 的令牌
+func (h *HostLimiter) Wait(ctx context.Context, host string) error {
+	h.mu.Lock()
+	b, ok := h.buckets[host]
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return b.Wait(ctx)
+}
+
+// NewCollector 创建 colly collector，请求前按 DefaultHostLimiter 限流。
+// colly 的 Visit 不接受 ctx，请求通过 ctxTransport 在 ctx 结束时取消
+func NewCollector(ctx context.Context) *colly.Collector {
+	c := colly.NewCollector()
+	c.WithTransport(ctxTransport{ctx: ctx, base: http.DefaultTransport})
+	c.OnRequest(func(r *colly.Request) {
+		if err := DefaultHostLimiter.Wait(ctx, r.URL.Host); err != nil {
+			r.Abort()
+		}
+	})
+	return c
+}
+
+// ctxTransport 给每个请求加上 ctx
+type ctxTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,153 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewCollectorCancelsWithContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := NewCollector(ctx).Visit(srv.URL)
+	if err == nil {
+		t.Fatal("Visit succeeded after the context ended")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Visit returned after %v, want about the ctx deadline", d)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(RateLimit{Rate: 20, Burst: 2})
+	ctx := context.Background()
+
+	// Burst 内不等待
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("burst took %v", d)
+	}
+
+	// 之后按 Rate 放行，每个 50ms
+	start = time.Now()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("third Wait took %v, want about 50ms", d)
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	b := NewTokenBucket(RateLimit{Rate: 1, Burst: 1})
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err == nil {
+		t.Fatal("Wait succeeded after the context ended")
+	}
+	// 取消的请求归还令牌，后面的请求不用多等一个
+	b.mu.Lock()
+	tokens := b.tokens
+	b.mu.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("tokens = %v after cancel, want about 0", tokens)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := NewTokenBucket(RateLimit{})
+	for i := 0; i < 100; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// hostLimits 当前每个 host 的限流
+func hostLimits(h *HostLimiter) map[string]RateLimit {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m := make(map[string]RateLimit, len(h.buckets))
+	for host, b := range h.buckets {
+		m[host] = b.limit
+	}
+	return m
+}
+
+func TestHostLimiter(t *testing.T) {
+	h := NewHostLimiter(map[string]RateLimit{"a.example.com": {Rate: 1, Burst: 1}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := h.Wait(ctx, "a.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Wait(ctx, "a.example.com"); err == nil {
+		t.Error("second Wait within the burst succeeded")
+	}
+	// 没有配置的 host 不限
+	for i := 0; i < 10; i++ {
+		if err := h.Wait(ctx, "b.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHostLimiterReplace(t *testing.T) {
+	h := NewHostLimiter(DefaultRateLimits)
+	h.Replace(map[string]RateLimit{
+		"qas.sylapp.cn": {Rate: 1, Burst: 1},
+		"extra.example": {Rate: 2, Burst: 2},
+	})
+	h.mu.Lock()
+	kept := h.buckets["stock.9fzt.com"]
+	h.mu.Unlock()
+
+	got := hostLimits(h)
+	if got["qas.sylapp.cn"] != (RateLimit{Rate: 1, Burst: 1}) {
+		t.Errorf("qas.sylapp.cn = %+v, want the configured limit", got["qas.sylapp.cn"])
+	}
+	if got["extra.example"] != (RateLimit{Rate: 2, Burst: 2}) {
+		t.Errorf("extra.example = %+v, want the configured limit", got["extra.example"])
+	}
+	for _, host := range []string{"web.ifzq.gtimg.cn", "money.finance.sina.com.cn"} {
+		if got[host] != DefaultRateLimits[host] {
+			t.Errorf("%s = %+v, want the default", host, got[host])
+		}
+	}
+
+	// 配置中删除后：覆盖的恢复默认，新增的不再限流，没变的保留令牌桶
+	h.Replace(nil)
+	got = hostLimits(h)
+	if !reflect.DeepEqual(got, DefaultRateLimits) {
+		t.Errorf("limits after Replace(nil) = %v, want DefaultRateLimits", got)
+	}
+	h.mu.Lock()
+	same := h.buckets["stock.9fzt.com"] == kept
+	h.mu.Unlock()
+	if !same {
+		t.Error("unchanged host got a new bucket")
+	}
+}
@@ -0,0 +1,132 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NoticeDuration 重新加载的提示在表格底部显示多久
+var NoticeDuration = time.Minute
+
+// DefaultConfigWatcher 监控运行中的 watchlist.yaml
+var DefaultConfigWatcher = NewConfigWatcher(configFile)
+
+// ConfigWatcher 通过文件的修改时间和大小发现配置变化，新文件校验通过后才替换，
+// 校验失败时继续使用原来的配置
+type ConfigWatcher struct {
+	path string
+
+	mu       sync.Mutex
+	conf     Config
+	loaded   bool
+	modTime  time.Time
+	size     int64
+	notice   string
+	noticeAt time.Time
+}
+
+func NewConfigWatcher(path string) *ConfigWatcher {
+	return &ConfigWatcher{path: path}
+}
+
+// Config 返回当前配置，文件有变化时先重新加载。只有第一次加载失败时返回错误
+func (w *ConfigWatcher) Config() (Config, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.loaded {
+		conf, err := ParseConfigFile()
+		if err != nil {
+			return Config{}, err
+		}
+		if err := conf.Apply(); err != nil {
+			return Config{}, err
+		}
+		w.conf = conf
+		w.loaded = true
+		if st, err := os.Stat(w.path); err == nil {
+			w.modTime, w.size = st.ModTime(), st.Size()
+		}
+		return w.conf, nil
+	}
+
+	st, err := os.Stat(w.path)
+	if err != nil {
+		w.setNotice(fmt.Sprintf("%s: %v, keeping previous watchlist", w.path, err))
+		return w.conf, nil
+	}
+	if st.ModTime().Equal(w.modTime) && st.Size() == w.size {
+		return w.conf, nil
+	}
+	w.modTime, w.size = st.ModTime(), st.Size()
+
+	bt, err := os.ReadFile(w.path)
+	if err == nil {
+		var conf Config
+		conf, err = ParseConfig(w.path, bt)
+		if err == nil {
+			err = conf.Apply()
+		}
+		if err == nil {
+			if msg := diffWatchlist(w.conf.Watchlist(), conf.Watchlist()); msg != "" {
+				w.setNotice(msg)
+			}
+			w.conf = conf
+			return w.conf, nil
+		}
+	}
+
+	w.setNotice(fmt.Sprintf("invalid %s, keeping previous watchlist: %s", w.path, strings.ReplaceAll(err.Error(), "\n", "; ")))
+	return w.conf, nil
+}
+
+// Notice 最近一次重新加载的提示，超过 NoticeDuration 后返回空
+func (w *ConfigWatcher) Notice() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.notice == "" || time.Since(w.noticeAt) > NoticeDuration {
+		return ""
+	}
+	return w.notice
+}
+
+func (w *ConfigWatcher) setNotice(msg string) {
+	w.notice = msg
+	w.noticeAt = time.Now()
+}
+
+// diffWatchlist 描述新增和删除的品种
+func diffWatchlist(old, new []Instrument) string {
+	names := func(list []Instrument) map[string]string {
+		m := make(map[string]string, len(list))
+		for _, v := range list {
+			m[v.Market+v.Code] = v.Code + " " + v.Name
+		}
+		return m
+	}
+	before, after := names(old), names(new)
+
+	var added, removed []string
+	for _, v := range new {
+		if _, ok := before[v.Market+v.Code]; !ok {
+			added = append(added, after[v.Market+v.Code])
+		}
+	}
+	for _, v := range old {
+		if _, ok := after[v.Market+v.Code]; !ok {
+			removed = append(removed, before[v.Market+v.Code])
+		}
+	}
+
+	parts := make([]string, 0, 2)
+	if len(added) > 0 {
+		parts = append(parts, "added: "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(removed, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
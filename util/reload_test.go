@@ -0,0 +1,115 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func providerNames(t InstrumentType) []string {
+	names := make([]string, 0)
+	for _, p := range Providers(t) {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+func TestConfigWatcherKeepsPreviousOnInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watchlist.yaml")
+	defer func(name string) { configFile = name }(configFile)
+	configFile = path
+	t.Cleanup(func() {
+		if err := (Config{Settings: DefaultSettings}).Apply(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	modTime := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 修改时间每次都不同，不依赖文件系统的时间精度
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const valid = `settings:
+  poll_interval: 3s
+  providers: [tencent]
+  db_path: db/a.db
+  rate_limits:
+    a.example: {rate: 1, burst: 1}
+instruments:
+  - {market: SZ, code: "000001", name: 平安银行, type: stock}
+`
+	write(valid)
+	w := NewConfigWatcher(path)
+	if _, err := w.Config(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(when string) {
+		t.Helper()
+		conf, err := w.Config()
+		if err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+		if list := conf.Watchlist(); len(list) != 1 || list[0].Code != "000001" {
+			t.Errorf("%s: watchlist = %+v", when, list)
+		}
+		if s := ActiveSettings(); s.PollInterval != 3*time.Second || s.DBPath != "db/a.db" {
+			t.Errorf("%s: active settings = %+v", when, s)
+		}
+		if names := providerNames(InstrumentStock); len(names) != 1 || names[0] != "tencent" {
+			t.Errorf("%s: providers = %v", when, names)
+		}
+		if limits := hostLimits(DefaultHostLimiter); limits["a.example"] != (RateLimit{Rate: 1, Burst: 1}) {
+			t.Errorf("%s: rate limits = %v", when, limits)
+		}
+		if n := w.Notice(); !strings.HasPrefix(n, "invalid "+path) {
+			t.Errorf("%s: notice = %q", when, n)
+		}
+	}
+
+	// YAML 语法错误
+	write(`settings:
+  poll_interval: 9s
+  providers: [sina
+instruments:
+  - {market: SH, code: "600000", type: stock}
+`)
+	check("invalid yaml")
+
+	// 设置本身可以解析，但是其中一项无效：一项都不生效
+	write(`settings:
+  poll_interval: 9s
+  providers: [sina]
+  db_path: db/b.db
+  rate_limits:
+    b.example: {rate: 2, burst: 2}
+  holiday_file: ` + filepath.Join(dir, "missing.txt") + `
+instruments:
+  - {market: SH, code: "600000", type: stock}
+`)
+	check("missing holiday file")
+
+	// 改正后全部生效
+	write(strings.Replace(valid, "3s", "9s", 1) + `  - {market: SH, code: "600000", name: 浦发银行, type: stock}
+`)
+	conf, err := w.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Watchlist()) != 2 || ActiveSettings().PollInterval != 9*time.Second {
+		t.Errorf("valid reload not applied: %+v, %+v", conf.Watchlist(), ActiveSettings())
+	}
+	if n := w.Notice(); n != "added: 600000 浦发银行" {
+		t.Errorf("notice = %q", n)
+	}
+}
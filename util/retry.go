@@ -0,0 +1,221 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTimeout 请求超时，errors.Is(err, ErrTimeout) 判断
+	ErrTimeout = errors.New("request timeout")
+	// ErrCircuitOpen 该 host 连续失败，熔断中
+	ErrCircuitOpen = errors.New("circuit breaker open")
+)
+
+// StatusError 非 200 的响应。errors.Is(err, &StatusError{Code: 503}) 可以判断具体的状态码
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Code == e.Code
+}
+
+// RequestError 请求失败，保留原始错误
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	if target != ErrTimeout {
+		return false
+	}
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(e.Err, &ne) && ne.Timeout()
+}
+
+// RetryPolicy 重试策略：指数退避加随机抖动。
+// 幂等请求在网络错误和 5xx 时重试，其他请求只在 RetryStatus 中的状态码时重试
+type RetryPolicy struct {
+	MaxAttempts int // 包括第一次
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	RetryStatus []int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+	RetryStatus: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+func (p RetryPolicy) shouldRetry(method string, err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		for _, code := range p.RetryStatus {
+			if code == se.Code {
+				return true
+			}
+		}
+		return isIdempotent(method) && se.Code >= 500
+	}
+	return isIdempotent(method)
+}
+
+// backoff 第 attempt 次重试前等待的时间（full jitter）
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CircuitBreaker 按 host 熔断：连续失败 Threshold 次后，Cooldown 时间内不再请求该 host，
+// 冷却结束后放行一个请求试探，成功则恢复
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	hosts     map[string]*breakerState
+	lastProbe uint64
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	probe     uint64 // 正在进行的试探请求，0 表示没有
+}
+
+var DefaultCircuitBreaker = NewCircuitBreaker(5, 30*time.Second)
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     make(map[string]*breakerState),
+	}
+}
+
+// Allow 是否可以请求该 host。放行的是冷却后的试探请求时 probe 不为 0，
+// 请求结束后必须把它交给 Record 或 Release，试探才结束
+func (b *CircuitBreaker) Allow(host string) (probe uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.hosts[host]
+	if !ok || st.failures < b.Threshold {
+		return 0, nil
+	}
+	if time.Now().Before(st.openUntil) || st.probe != 0 {
+		return 0, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+	b.lastProbe++
+	st.probe = b.lastProbe
+	return st.probe, nil
+}
+
+// Record 记录一次请求的结果，probe 是 Allow 返回的值。
+// 只有试探请求本身结束时才结束试探，熔断前发出的请求晚到的结果不会再放行一个试探
+func (b *CircuitBreaker) Record(host string, probe uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, exists := b.hosts[host]
+	if !exists {
+		if ok {
+			return
+		}
+		st = &breakerState{}
+		b.hosts[host] = st
+	}
+	if probe != 0 && st.probe == probe {
+		st.probe = 0
+	}
+	if ok {
+		// 任何一个请求成功都恢复，还没结束的试探作废
+		st.failures = 0
+		st.probe = 0
+		return
+	}
+	st.failures++
+	if st.failures >= b.Threshold {
+		st.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// Release 请求没有结果（调用方取消）时结束试探，不改变失败次数
+func (b *CircuitBreaker) Release(host string, probe uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if st, ok := b.hosts[host]; ok && probe != 0 && st.probe == probe {
+		st.probe = 0
+	}
+}
+
+// breakerFailure 网络错误和 5xx 计入熔断，4xx 是请求本身的问题。
+// 调用方取消的请求不会走到这里，由 Release 处理
+func breakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	p := DefaultRetryPolicy
+	netErr := &RequestError{Method: "GET", URL: "http://example.com", Err: errors.New("connection reset")}
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"get network error", http.MethodGet, netErr, true},
+		{"post network error", http.MethodPost, netErr, false},
+		{"get 500", http.MethodGet, &StatusError{Code: 500, Status: "500 Internal Server Error"}, true},
+		{"post 500", http.MethodPost, &StatusError{Code: 500, Status: "500 Internal Server Error"}, false},
+		{"post 503 in RetryStatus", http.MethodPost, &StatusError{Code: 503, Status: "503 Service Unavailable"}, true},
+		{"post 429 in RetryStatus", http.MethodPost, &StatusError{Code: 429, Status: "429 Too Many Requests"}, true},
+		{"get 404", http.MethodGet, &StatusError{Code: 404, Status: "404 Not Found"}, false},
+		{"wrapped status", http.MethodGet, fmt.Errorf("quote: %w", &StatusError{Code: 502, Status: "502 Bad Gateway"}), true},
+		{"circuit open", http.MethodGet, fmt.Errorf("%w: example.com", ErrCircuitOpen), false},
+		{"canceled", http.MethodGet, &RequestError{Method: "GET", Err: context.Canceled}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.shouldRetry(tt.method, tt.err); got != tt.want {
+				t.Errorf("shouldRetry(%s, %v) = %v, want %v", tt.method, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},  // 超过 MaxDelay
+		{70, time.Second}, // 移位溢出
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tt.attempt); d < 0 || d >= tt.max {
+				t.Fatalf("backoff(%d) = %v, want in [0, %v)", tt.attempt, d, tt.max)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(2); d != 0 {
+		t.Errorf("backoff without delays = %v, want 0", d)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const host = "example.com"
+	b := NewCircuitBreaker(2, time.Hour)
+	allow := func() (uint64, error) {
+		t.Helper()
+		return b.Allow(host)
+	}
+	expire := func() {
+		b.mu.Lock()
+		b.hosts[host].openUntil = time.Now().Add(-time.Second)
+		b.mu.Unlock()
+	}
+
+	// 关闭：失败次数没到 Threshold
+	b.Record(host, 0, false)
+	if probe, err := allow(); err != nil || probe != 0 {
+		t.Fatalf("Allow after one failure = %d, %v", probe, err)
+	}
+
+	// 打开：冷却中拒绝
+	b.Record(host, 0, false)
+	if _, err := allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow while open = %v, want ErrCircuitOpen", err)
+	}
+
+	// 半开：冷却结束只放行一个试探
+	expire()
+	probe, err := allow()
+	if err != nil || probe == 0 {
+		t.Fatalf("Allow after cooldown = %d, %v, want a probe", probe, err)
+	}
+	if _, err := allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second Allow while probing = %v, want ErrCircuitOpen", err)
+	}
+
+	// 熔断前发出的请求晚到的结果不结束试探
+	expire()
+	b.Record(host, 0, false)
+	expire()
+	b.Release(host, 0)
+	if _, err := allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow after a stale result = %v, want ErrCircuitOpen", err)
+	}
+
+	// 试探被取消：可以再试探一次
+	b.Release(host, probe)
+	probe, err = allow()
+	if err != nil || probe == 0 {
+		t.Fatalf("Allow after released probe = %d, %v, want a new probe", probe, err)
+	}
+
+	// 试探失败：重新冷却
+	b.Record(host, probe, false)
+	if _, err := allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow after failed probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// 试探成功：恢复
+	expire()
+	probe, err = allow()
+	if err != nil || probe == 0 {
+		t.Fatalf("Allow after cooldown = %d, %v, want a probe", probe, err)
+	}
+	b.Record(host, probe, true)
+	for i := 0; i < 3; i++ {
+		if probe, err := allow(); err != nil || probe != 0 {
+			t.Fatalf("Allow after recovery = %d, %v", probe, err)
+		}
+	}
+}
+
+func TestBreakerFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&StatusError{Code: 404}, false},
+		{&StatusError{Code: 503}, true},
+		{&RequestError{Err: errors.New("connection refused")}, true},
+	}
+	for _, tt := range tests {
+		if got := breakerFailure(tt.err); got != tt.want {
+			t.Errorf("breakerFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"fmt"
+	"go-colly/calendar"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session 沪深交易所的交易时段
+type Session int
+
+const (
+	SessionClosed         Session = iota // 非交易日或收盘后
+	SessionCallAuction                   // 9:15-9:25 开盘集合竞价
+	SessionPreOpen                       // 9:25-9:30 开盘价已确定，等待连续竞价
+	SessionContinuous                    // 9:30-11:30、13:00-14:57 连续竞价
+	SessionLunchBreak                    // 11:30-13:00 午间休市
+	SessionClosingAuction                // 14:57-15:00 收盘集合竞价
+	SessionAfterHours                    // 15:05-15:30 科创板、创业板盘后固定价格交易
+)
+
+func (s Session) String() string {
+	switch s {
+	case SessionCallAuction:
+		return "call auction"
+	case SessionPreOpen:
+		return "pre-open"
+	case SessionContinuous:
+		return "continuous trading"
+	case SessionLunchBreak:
+		return "lunch break"
+	case SessionClosingAuction:
+		return "closing auction"
+	case SessionAfterHours:
+		return "after-hours"
+	}
+	return "closed"
+}
+
+// Active 价格会变化，需要按 PollInterval 拉取
+func (s Session) Active() bool {
+	switch s {
+	case SessionCallAuction, SessionContinuous, SessionClosingAuction, SessionAfterHours:
+		return true
+	}
+	return false
+}
+
+type sessionWindow struct {
+	start, end int // 一天中的第几分钟，北京时间
+	session    Session
+}
+
+var sessionWindows = []sessionWindow{
+	{9*60 + 15, 9*60 + 25, SessionCallAuction},
+	{9*60 + 25, 9*60 + 30, SessionPreOpen},
+	{9*60 + 30, 11*60 + 30, SessionContinuous},
+	{11*60 + 30, 13 * 60, SessionLunchBreak},
+	{13 * 60, 14*60 + 57, SessionContinuous},
+	{14*60 + 57, 15 * 60, SessionClosingAuction},
+	{15*60 + 5, 15*60 + 30, SessionAfterHours},
+}
+
+// IdleInterval 非交易时段多久醒来一次，只刷新显示的状态，不拉取行情
+var IdleInterval = time.Minute
+
+// DefaultScheduler 使用 watchlist.yaml 中 holiday_file 指定的日历，没有指定时用内置的日历
+var DefaultScheduler = NewScheduler(calendar.Default())
+
+// Scheduler 根据交易时段决定什么时候拉取行情
+type Scheduler struct {
+	mu         sync.Mutex
+	cal        *calendar.Calendar
+	afterHours bool
+	lastActive bool
+	polled     bool
+}
+
+func NewScheduler(cal *calendar.Calendar) *Scheduler {
+	return &Scheduler{cal: cal}
+}
+
+// SetCalendar 替换交易日历，日历不包括今年时打印警告
+func (s *Scheduler) SetCalendar(cal *calendar.Calendar) {
+	s.mu.Lock()
+	s.cal = cal
+	s.mu.Unlock()
+	if w := s.CalendarWarning(time.Now()); w != "" {
+		log.Println(w)
+	}
+}
+
+// CalendarWarning 日历的休市日期不包括 now 所在的年份时返回警告，这时节假日会被当成交易日
+func (s *Scheduler) CalendarWarning(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cal.Covers(now) {
+		return ""
+	}
+	return fmt.Sprintf("holiday calendar ends in %d, update holidays.txt or holiday_file", s.cal.LastYear())
+}
+
+// SetAfterHours 自选列表中有科创板或创业板时，盘后固定价格交易时段也拉取
+func (s *Scheduler) SetAfterHours(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.afterHours = on
+}
+
+// TradingDay t 所在的那一天是否开市
+func (s *Scheduler) TradingDay(t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cal.IsTradingDay(t)
+}
+
+// Session t 所在的交易时段
+func (s *Scheduler) Session(t time.Time) Session {
+	if !s.TradingDay(t) {
+		return SessionClosed
+	}
+	t = t.In(ShanghaiLocation())
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range sessionWindows {
+		if minute >= w.start && minute < w.end {
+			if w.session == SessionAfterHours && !s.hasAfterHours() {
+				return SessionClosed
+			}
+			return w.session
+		}
+	}
+	return SessionClosed
+}
+
+func (s *Scheduler) hasAfterHours() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.afterHours
+}
+
+// NextOpen t 之后下一个需要拉取的时段开始的时间
+func (s *Scheduler) NextOpen(t time.Time) time.Time {
+	t = t.In(ShanghaiLocation())
+	day := calendar.Date(t)
+	for i := 0; i < 60; i++ {
+		d := day.AddDate(0, 0, i)
+		if !s.TradingDay(d) {
+			continue
+		}
+		for _, w := range sessionWindows {
+			if !w.session.Active() || (w.session == SessionAfterHours && !s.hasAfterHours()) {
+				continue
+			}
+			start := d.Add(time.Duration(w.start) * time.Minute)
+			if start.After(t) {
+				return start
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Due 现在是否需要拉取：交易时段内、第一次、或者刚离开交易时段（拿到收盘价）
+func (s *Scheduler) Due(now time.Time) bool {
+	active := s.Session(now).Active()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := active || s.lastActive || !s.polled
+	s.lastActive = active
+	if due {
+		s.polled = true
+	}
+	return due
+}
+
+// Wait 下一次检查前等待多久，交易时段内为 interval，其他时候等到下一个时段开始，最多 IdleInterval
+func (s *Scheduler) Wait(now time.Time, interval time.Duration) time.Duration {
+	if s.Session(now).Active() {
+		return interval
+	}
+	next := s.NextOpen(now)
+	if next.IsZero() {
+		return IdleInterval
+	}
+	d := next.Sub(now)
+	if d > IdleInterval {
+		return IdleInterval
+	}
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
+
+// Status 表格底部显示的交易状态
+func (s *Scheduler) Status(now time.Time) string {
+	status := s.sessionStatus(now)
+	if w := s.CalendarWarning(now); w != "" {
+		status += " (" + w + ")"
+	}
+	return status
+}
+
+func (s *Scheduler) sessionStatus(now time.Time) string {
+	session := s.Session(now)
+	if session.Active() {
+		return "Session: " + session.String()
+	}
+	next := s.NextOpen(now)
+	if next.IsZero() {
+		return "Session: " + session.String()
+	}
+	layout := "15:04"
+	if next.Format("20060102") != now.In(ShanghaiLocation()).Format("20060102") {
+		layout = "2006-01-02 15:04"
+	}
+	return fmt.Sprintf("Session: %s, polling resumes %s", session, next.Format(layout))
+}
+
+// hasAfterHoursBoard 科创板 688、689 和创业板 300、301 有盘后固定价格交易
+func hasAfterHoursBoard(ins Instrument) bool {
+	if ins.Market == "SH" {
+		return strings.HasPrefix(ins.Code, "688") || strings.HasPrefix(ins.Code, "689")
+	}
+	return strings.HasPrefix(ins.Code, "300") || strings.HasPrefix(ins.Code, "301")
+}
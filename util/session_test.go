@@ -0,0 +1,187 @@
+package util
+
+import (
+	"go-colly/calendar"
+	"testing"
+	"time"
+)
+
+// shanghai 解析北京时间 2006-01-02 15:04:05
+func shanghai(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04:05.999", s, ShanghaiLocation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func newTestScheduler(afterHours bool) *Scheduler {
+	s := NewScheduler(calendar.Default())
+	s.SetAfterHours(afterHours)
+	return s
+}
+
+func TestSchedulerSession(t *testing.T) {
+	tests := []struct {
+		at         string
+		want       Session
+		afterHours Session // 有科创板、创业板时
+	}{
+		{"2024-05-17 09:14:59", SessionClosed, SessionClosed},
+		{"2024-05-17 09:15:00", SessionCallAuction, SessionCallAuction},
+		{"2024-05-17 09:24:59", SessionCallAuction, SessionCallAuction},
+		{"2024-05-17 09:25:00", SessionPreOpen, SessionPreOpen},
+		{"2024-05-17 09:30:00", SessionContinuous, SessionContinuous},
+		{"2024-05-17 11:29:59", SessionContinuous, SessionContinuous},
+		{"2024-05-17 11:30:00", SessionLunchBreak, SessionLunchBreak},
+		{"2024-05-17 13:00:00", SessionContinuous, SessionContinuous},
+		{"2024-05-17 14:56:59", SessionContinuous, SessionContinuous},
+		{"2024-05-17 14:57:00", SessionClosingAuction, SessionClosingAuction},
+		{"2024-05-17 15:00:00", SessionClosed, SessionClosed},
+		{"2024-05-17 15:04:59", SessionClosed, SessionClosed},
+		{"2024-05-17 15:05:00", SessionClosed, SessionAfterHours},
+		{"2024-05-17 15:29:59", SessionClosed, SessionAfterHours},
+		{"2024-05-17 15:30:00", SessionClosed, SessionClosed},
+		{"2024-05-18 10:00:00", SessionClosed, SessionClosed}, // 周六
+		{"2024-05-01 10:00:00", SessionClosed, SessionClosed}, // 劳动节
+		{"2024-10-07 10:00:00", SessionClosed, SessionClosed}, // 国庆节最后一天是周一
+		{"2024-10-08 10:00:00", SessionContinuous, SessionContinuous},
+	}
+	plain, after := newTestScheduler(false), newTestScheduler(true)
+	for _, tt := range tests {
+		at := shanghai(t, tt.at)
+		if got := plain.Session(at); got != tt.want {
+			t.Errorf("Session(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+		if got := after.Session(at); got != tt.afterHours {
+			t.Errorf("Session(%s) with after-hours = %s, want %s", tt.at, got, tt.afterHours)
+		}
+	}
+
+	// 按北京时间判断，和传入的时区无关
+	utc := shanghai(t, "2024-05-17 09:30:00").UTC()
+	if got := plain.Session(utc); got != SessionContinuous {
+		t.Errorf("Session(%s) = %s, want continuous trading", utc, got)
+	}
+}
+
+func TestHasAfterHoursBoard(t *testing.T) {
+	tests := []struct {
+		market, code string
+		want         bool
+	}{
+		{"SH", "688981", true}, // 科创板
+		{"SH", "689009", true}, // 科创板 CDR
+		{"SZ", "300750", true}, // 创业板
+		{"SZ", "301236", true},
+		{"SH", "600000", false},
+		{"SH", "510300", false},
+		{"SZ", "000001", false},
+		{"SZ", "002139", false},
+		{"SZ", "159915", false}, // 创业板 ETF 没有盘后固定价格交易
+		{"SH", "300001", false},
+		{"SZ", "688001", false},
+	}
+	for _, tt := range tests {
+		if got := hasAfterHoursBoard(Instrument{Market: tt.market, Code: tt.code}); got != tt.want {
+			t.Errorf("hasAfterHoursBoard(%s %s) = %v, want %v", tt.market, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerNextOpen(t *testing.T) {
+	tests := []struct {
+		at         string
+		afterHours bool
+		want       string
+	}{
+		{"2024-05-17 08:00:00", false, "2024-05-17 09:15:00"},
+		{"2024-05-17 09:15:00", false, "2024-05-17 09:30:00"}, // 开始时间之后的下一个
+		{"2024-05-17 09:26:00", false, "2024-05-17 09:30:00"}, // 9:25-9:30 不拉取
+		{"2024-05-17 11:45:00", false, "2024-05-17 13:00:00"},
+		{"2024-05-17 13:30:00", false, "2024-05-17 14:57:00"},
+		{"2024-05-17 15:00:00", false, "2024-05-20 09:15:00"}, // 周五收盘后到下周一
+		{"2024-05-17 15:00:00", true, "2024-05-17 15:05:00"},
+		{"2024-05-17 15:10:00", true, "2024-05-20 09:15:00"},
+		{"2024-05-18 12:00:00", true, "2024-05-20 09:15:00"},
+		{"2024-04-30 15:30:00", false, "2024-05-06 09:15:00"}, // 劳动节 5 月 1 日至 3 日，接着周末
+		{"2024-09-30 16:00:00", false, "2024-10-08 09:15:00"}, // 国庆节
+		{"2024-02-08 15:30:00", false, "2024-02-19 09:15:00"}, // 春节
+		{"2023-12-29 16:00:00", false, "2024-01-02 09:15:00"}, // 元旦
+	}
+	for _, tt := range tests {
+		s := newTestScheduler(tt.afterHours)
+		got := s.NextOpen(shanghai(t, tt.at))
+		if want := shanghai(t, tt.want); !got.Equal(want) {
+			t.Errorf("NextOpen(%s, after-hours %v) = %s, want %s", tt.at, tt.afterHours, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerWait(t *testing.T) {
+	defer func(d time.Duration) { IdleInterval = d }(IdleInterval)
+	IdleInterval = time.Minute
+	const interval = 5 * time.Second
+
+	tests := []struct {
+		at   string
+		want time.Duration
+	}{
+		{"2024-05-17 10:00:00", interval},
+		{"2024-05-17 14:58:00", interval},         // 收盘集合竞价
+		{"2024-05-17 08:00:00", time.Minute},      // 最多等 IdleInterval
+		{"2024-05-17 09:14:30", 30 * time.Second}, // 到集合竞价开始
+		{"2024-05-17 09:14:59.6", time.Second},    // 最少一秒
+		{"2024-05-17 09:29:40", 20 * time.Second}, // 开盘价已确定，等连续竞价
+		{"2024-05-17 12:59:30", 30 * time.Second},
+		{"2024-05-18 10:00:00", time.Minute},
+		{"2024-05-01 10:00:00", time.Minute},
+	}
+	s := newTestScheduler(false)
+	for _, tt := range tests {
+		if got := s.Wait(shanghai(t, tt.at), interval); got != tt.want {
+			t.Errorf("Wait(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerDue(t *testing.T) {
+	s := newTestScheduler(false)
+	steps := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-05-18 10:00:00", true},  // 第一次总是拉取
+		{"2024-05-18 10:01:00", false}, // 周末
+		{"2024-05-20 09:15:00", true},
+		{"2024-05-20 09:26:00", true},  // 刚离开集合竞价，拿到开盘价
+		{"2024-05-20 09:27:00", false}, // 开盘价已确定
+		{"2024-05-20 14:59:00", true},
+		{"2024-05-20 15:00:10", true}, // 收盘后拉取一次收盘价
+		{"2024-05-20 15:01:00", false},
+	}
+	for _, step := range steps {
+		if got := s.Due(shanghai(t, step.at)); got != step.want {
+			t.Errorf("Due(%s) = %v, want %v", step.at, got, step.want)
+		}
+	}
+}
+
+func TestSchedulerStatus(t *testing.T) {
+	s := newTestScheduler(false)
+	tests := []struct {
+		at, want string
+	}{
+		{"2024-05-17 10:00:00", "Session: continuous trading"},
+		{"2024-05-17 12:00:00", "Session: lunch break, polling resumes 13:00"},
+		{"2024-05-17 16:00:00", "Session: closed, polling resumes 2024-05-20 09:15"},
+		{"2024-05-01 10:00:00", "Session: closed, polling resumes 2024-05-06 09:15"},
+		{"2030-05-17 10:00:00", "Session: continuous trading (holiday calendar ends in 2026, update holidays.txt or holiday_file)"},
+	}
+	for _, tt := range tests {
+		if got := s.Status(shanghai(t, tt.at)); got != tt.want {
+			t.Errorf("Status(%s) = %q, want %q", tt.at, got, tt.want)
+		}
+	}
+}
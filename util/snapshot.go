@@ -0,0 +1,79 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SnapshotStore 保存报价快照，实现在 store 包
+type SnapshotStore interface {
+	// LatestSnapshots 每个品种最近保存的一条
+	LatestSnapshots(ctx context.Context) (map[string]KlineData, error)
+	// InsertSnapshots 在一个事务中写入一轮报价，at 为拉取的时间
+	InsertSnapshots(ctx context.Context, at time.Time, quotes []KlineData) error
+}
+
+// snapshotKey 判断报价有没有变化的字段
+type snapshotKey struct {
+	open, high, low, close float64
+	volume                 int64
+	amount                 float64
+}
+
+func snapshotKeyOf(v KlineData) snapshotKey {
+	return snapshotKey{v.Open, v.High, v.Low, v.Close, v.Volume, v.Amount}
+}
+
+// SnapshotWriter 把每一轮的报价写入 quote_snapshot。
+// 和同一品种上一次保存的相比没有变化的不再保存，超时沿用上一次数据的（Stale）也不保存
+type SnapshotWriter struct {
+	store SnapshotStore
+
+	mu   sync.Mutex
+	last map[string]snapshotKey
+}
+
+// NewSnapshotWriter 读取每个品种最近保存的一条用于去重
+func NewSnapshotWriter(ctx context.Context, store SnapshotStore) (*SnapshotWriter, error) {
+	latest, err := store.LatestSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &SnapshotWriter{store: store, last: make(map[string]snapshotKey, len(latest))}
+	for symbol, v := range latest {
+		w.last[symbol] = snapshotKeyOf(v)
+	}
+	return w, nil
+}
+
+// Write 写入一轮报价，返回写入的条数
+func (w *SnapshotWriter) Write(ctx context.Context, at time.Time, quotes []KlineData) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := make([]KlineData, 0, len(quotes))
+	for _, v := range quotes {
+		if v.Stale || v.Symbol == "" || v.Close <= 0 {
+			continue
+		}
+		if k, ok := w.last[v.Symbol]; ok && k == snapshotKeyOf(v) {
+			continue
+		}
+		changed = append(changed, v)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	if err := w.store.InsertSnapshots(ctx, at, changed); err != nil {
+		return 0, err
+	}
+
+	// 写入成功后才更新，失败时下一轮重新写入
+	for _, v := range changed {
+		w.last[v.Symbol] = snapshotKeyOf(v)
+	}
+	return len(changed), nil
+}
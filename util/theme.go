@@ -0,0 +1,113 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// Theme 涨跌的颜色
+type Theme struct {
+	Name string
+	Up   text.Colors
+	Down text.Colors
+	Flat text.Colors
+}
+
+var themes = map[string]Theme{
+	// A 股习惯：红涨绿跌
+	"cn": {Name: "cn", Up: text.Colors{text.FgRed}, Down: text.Colors{text.FgGreen}},
+	// 欧美习惯：绿涨红跌
+	"western": {Name: "western", Up: text.Colors{text.FgGreen}, Down: text.Colors{text.FgRed}},
+	// 色盲友好：蓝涨橙跌，同时加粗上涨
+	"colorblind": {Name: "colorblind", Up: text.Colors{text.FgHiBlue, text.Bold}, Down: text.Colors{text.FgHiYellow}},
+	// 不带颜色，适合重定向到文件或管道
+	"mono": {Name: "mono"},
+}
+
+// DefaultTheme 没有配置 theme 时使用
+const DefaultTheme = "cn"
+
+// ThemeNames 所有主题的名字
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for k := range themes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetTheme 按名字查找主题，为空时返回 DefaultTheme
+func GetTheme(name string) (Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	t, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q, available: %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return t, nil
+}
+
+// Paint 按涨跌值的正负给文字上色
+func (t Theme) Paint(s string, change float64) string {
+	var colors text.Colors
+	switch {
+	case change > 0:
+		colors = t.Up
+	case change < 0:
+		colors = t.Down
+	default:
+		colors = t.Flat
+	}
+	if len(colors) == 0 {
+		return s
+	}
+	return colors.Sprint(s)
+}
+
+var (
+	ttyOnce sync.Once
+	isTTY   bool
+)
+
+// IsTerminal 标准输出是否为终端，重定向到文件或管道时为 false
+func IsTerminal() bool {
+	ttyOnce.Do(func() {
+		st, err := os.Stdout.Stat()
+		isTTY = err == nil && st.Mode()&os.ModeCharDevice != 0
+	})
+	return isTTY
+}
+
+// ColorEnabled 标准输出是终端并且没有设置 NO_COLOR 时才输出颜色
+func ColorEnabled() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return IsTerminal()
+}
+
+// ThemeOverride 命令行指定的主题，优先于配置
+var ThemeOverride string
+
+// ActiveTheme 配置中的主题，输出不是终端时为 mono
+func ActiveTheme() Theme {
+	if !ColorEnabled() {
+		return themes["mono"]
+	}
+	name := ActiveSettings().Theme
+	if ThemeOverride != "" {
+		name = ThemeOverride
+	}
+	t, err := GetTheme(name)
+	if err != nil {
+		return themes[DefaultTheme]
+	}
+	return t
+}
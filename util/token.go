@@ -0,0 +1,327 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrTokenInvalid 九方智投返回 6403，token 失效
+var ErrTokenInvalid = errors.New("token invalid")
+
+/*
+	{
+	    "Code": "0000",
+	    "Message": "ok",
+	    "ReqID": 0,
+	    "QuoteData": {
+	        "AuthData": [
+	            {
+	                "Token": "07d8080f-85d7-11ef-8244-1e47b4029c79",
+	                "Tag": "mytag123456",
+	                "ExpireTime": 86400
+	            }
+	        ]
+	    }
+	}
+*/
+type ResponseDataLogin struct {
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	ReqID     int    `json:"ReqID"`
+	QuoteData struct {
+		AuthData []AuthData `json:"AuthData"`
+	} `json:"QuoteData"`
+}
+
+type AuthData struct {
+	Token      string `json:"Token"`
+	Tag        string `json:"Tag"`
+	ExpireTime int64  `json:"ExpireTime"` // 有效期，秒
+}
+
+// DefaultTokenManager 九方智投的 token，保存在 tokens.json
+var DefaultTokenManager = NewTokenManager(LoginJFZT)
+
+func init() {
+	DefaultTokenManager.Store = NewTokenStore(tokenFile)
+	DefaultTokenManager.Provider = "jfzt"
+}
+
+// TokenInfo token 及其签发、过期时间，未知时为零值
+type TokenInfo struct {
+	Token     string    `json:"token"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TokenManager 管理 token 的生命周期：过期前自动刷新，失效时重新登录。可以在多个 goroutine 中使用
+type TokenManager struct {
+	// RefreshBefore 提前多久刷新
+	RefreshBefore time.Duration
+	// Store 保存 token，为 nil 时只保存在内存中
+	Store *TokenStore
+	// Provider 在 Store 中的名称
+	Provider string
+	// LoginBackoff 登录失败后多久内不再登录，直接返回上一次的错误
+	LoginBackoff time.Duration
+	// LoginTimeout 一次登录最长的时间。登录不使用调用者的 ctx，调用者超时后登录继续，下一轮直接使用结果
+	LoginTimeout time.Duration
+
+	mu       sync.Mutex
+	info     TokenInfo
+	login    func(ctx context.Context) (AuthData, error)
+	inflight *loginFlight // 正在登录时不为 nil
+	lastErr  error        // 上一次登录的错误
+	retryAt  time.Time    // 在这之前不再登录
+
+	saveMu sync.Mutex // 保存到 Store 时持有，不持有 mu
+}
+
+// loginFlight 一次正在进行的登录，done 关闭后 err 为登录的结果
+type loginFlight struct {
+	done chan struct{}
+	err  error
+}
+
+func NewTokenManager(login func(ctx context.Context) (AuthData, error)) *TokenManager {
+	return &TokenManager{
+		RefreshBefore: 10 * time.Minute,
+		LoginBackoff:  30 * time.Second,
+		LoginTimeout:  30 * time.Second,
+		login:         login,
+	}
+}
+
+// Seed 设置一个来源于配置文件的 token，内存和 Store 中都没有 token 时才使用
+func (m *TokenManager) Seed(token string) {
+	if token == "" || m.Info().Token != "" {
+		return
+	}
+	info, stored := TokenInfo{Token: token}, false
+	if m.Store != nil {
+		if v, ok, err := m.Store.Load(m.Provider); err == nil && ok {
+			info, stored = v, true
+		}
+	}
+
+	m.mu.Lock()
+	if m.info.Token != "" {
+		m.mu.Unlock()
+		return
+	}
+	m.info = info
+	m.mu.Unlock()
+	if !stored {
+		m.save()
+	}
+}
+
+// Set 使用指定的 token，例如命令行参数，签发和过期时间未知
+func (m *TokenManager) Set(token string) {
+	m.mu.Lock()
+	m.info = TokenInfo{Token: token}
+	m.lastErr = nil
+	m.mu.Unlock()
+	m.save()
+}
+
+// Info 当前 token 的信息
+func (m *TokenManager) Info() TokenInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.info
+}
+
+// Token 返回可用的 token，没有或者快过期时重新登录。
+// 同一时间只有一个登录，在后台用 LoginTimeout 执行；调用者等它结束或者自己的 ctx 结束。
+// 登录失败后 LoginBackoff 内直接返回该错误
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		if m.valid(now) {
+			token := m.info.Token
+			m.mu.Unlock()
+			return token, nil
+		}
+		if m.lastErr != nil && now.Before(m.retryAt) {
+			err := m.lastErr
+			m.mu.Unlock()
+			return "", err
+		}
+		f := m.inflight
+		if f == nil {
+			f = &loginFlight{done: make(chan struct{})}
+			m.inflight = f
+			go m.runLogin(f, m.info.Token)
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-f.done:
+			if f.err != nil {
+				return "", f.err
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// runLogin 不使用调用者的 ctx，一轮的截止时间比登录短时也能登录完成
+func (m *TokenManager) runLogin(f *loginFlight, current string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.LoginTimeout)
+	defer cancel()
+	info, err := m.refresh(ctx, current)
+
+	m.mu.Lock()
+	m.inflight = nil
+	if err == nil {
+		m.info = info
+		m.lastErr = nil
+	} else {
+		m.lastErr = err
+		m.retryAt = time.Now().Add(m.LoginBackoff)
+	}
+	m.mu.Unlock()
+	if err == nil {
+		m.save()
+	}
+
+	f.err = err
+	close(f.done)
+}
+
+// Invalidate 标记 token 失效。只有 token 仍是当前值时才清除，
+// 这样多个请求同时拿到 6403 时只会重新登录一次
+func (m *TokenManager) Invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.info.Token == token {
+		m.info.ExpiresAt = time.Now()
+	}
+}
+
+// Do 用当前 token 执行 fn，返回 ErrTokenInvalid 时重新登录并重试一次
+func (m *TokenManager) Do(ctx context.Context, fn func(token string) error) error {
+	token, err := m.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(token)
+	if !errors.Is(err, ErrTokenInvalid) {
+		return err
+	}
+
+	m.Invalidate(token)
+	token, err = m.Token(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+func (m *TokenManager) valid(now time.Time) bool {
+	return m.usable(m.info, now)
+}
+
+func (m *TokenManager) usable(info TokenInfo, now time.Time) bool {
+	if info.Token == "" {
+		return false
+	}
+	if info.ExpiresAt.IsZero() {
+		// 过期时间未知，等接口返回 6403 再刷新
+		return true
+	}
+	return now.Before(info.ExpiresAt.Add(-m.RefreshBefore))
+}
+
+// refresh 不持有 mu：先看 Store 中有没有另一个程序刷新过的 token，没有时登录
+func (m *TokenManager) refresh(ctx context.Context, current string) (TokenInfo, error) {
+	if m.Store != nil {
+		info, ok, err := m.Store.Load(m.Provider)
+		if err != nil {
+			log.Println(err)
+		} else if ok && info.Token != current && m.usable(info, time.Now()) {
+			return info, nil
+		}
+	}
+
+	auth, err := m.login(ctx)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("login failed: %w", err)
+	}
+
+	now := time.Now()
+	info := TokenInfo{Token: auth.Token, IssuedAt: now}
+	if auth.ExpireTime > 0 {
+		info.ExpiresAt = now.Add(time.Duration(auth.ExpireTime) * time.Second)
+	}
+	return info, nil
+}
+
+// save 把当前的 token 写入 Store，调用时不能持有 mu。
+// 保存时再取 info，多个 save 同时调用时最后写入的是最新的
+func (m *TokenManager) save() {
+	if m.Store == nil {
+		return
+	}
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	if err := m.Store.Save(m.Provider, m.Info()); err != nil {
+		log.Println(err)
+	}
+}
+
+// LoginJFZT 九方智投自动登录，返回新的 token 和有效期
+func LoginJFZT(ctx context.Context) (AuthData, error) {
+	pretoken, err := FetchBootstrapToken(ctx)
+	if err != nil {
+		return AuthData{}, err
+	}
+
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/json"
+
+	body := make(map[string]string)
+	body["OrgCode"] = "rh"
+	body["Token"] = pretoken
+	body["AppName"] = "tctest"
+	body["AppVer"] = "V3.1.9"
+	body["AppType"] = "ios"
+	body["Tag"] = "mytag123456"
+	bt, _ := json.Marshal(body)
+
+	rp, err := HttpRequestContext(ctx, "https://qas.sylapp.cn/api/v30/login", "POST", headers, string(bt))
+	if err != nil {
+		return AuthData{}, err
+	}
+
+	var respData ResponseDataLogin
+	if err := json.Unmarshal([]byte(rp), &respData); err != nil {
+		return AuthData{}, err
+	}
+	if respData.Code != "0000" {
+		return AuthData{}, errors.New(respData.Message)
+	}
+	if len(respData.QuoteData.AuthData) == 0 || respData.QuoteData.AuthData[0].Token == "" {
+		return AuthData{}, errors.New("login response has no AuthData")
+	}
+	return respData.QuoteData.AuthData[0], nil
+}
+
+// GetTokenFromWebsite 登录并返回新的 token，失败时返回空字符串
+func GetTokenFromWebsite() string {
+	auth, err := LoginJFZT(context.Background())
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return auth.Token
+}
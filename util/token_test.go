@@ -0,0 +1,75 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenManagerLoginOutlivesCaller(t *testing.T) {
+	var logins int32
+	release := make(chan struct{})
+	m := NewTokenManager(func(ctx context.Context) (AuthData, error) {
+		atomic.AddInt32(&logins, 1)
+		select {
+		case <-release:
+			return AuthData{Token: "t1", ExpireTime: 86400}, nil
+		case <-ctx.Done():
+			return AuthData{}, ctx.Err()
+		}
+	})
+	m.Store = NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	m.Provider = "test"
+
+	// 一轮的 ctx 比登录短：调用者超时返回，登录不取消
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := m.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want deadline exceeded", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	token, err := m.Token(context.Background())
+	if err != nil || token != "t1" {
+		t.Fatalf("Token = %q, %v, want t1", token, err)
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("logins = %d, want 1", n)
+	}
+	if info, ok, err := m.Store.Load("test"); err != nil || !ok || info.Token != "t1" {
+		t.Errorf("stored = %+v, %v, %v, want t1", info, ok, err)
+	}
+}
+
+func TestTokenManagerLoginTimeout(t *testing.T) {
+	var logins int32
+	m := NewTokenManager(func(ctx context.Context) (AuthData, error) {
+		atomic.AddInt32(&logins, 1)
+		<-ctx.Done()
+		return AuthData{}, ctx.Err()
+	})
+	m.LoginTimeout = 10 * time.Millisecond
+
+	if _, err := m.Token(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want login deadline exceeded", err)
+	}
+	// LoginBackoff 内直接返回上一次的错误
+	if _, err := m.Token(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the last login error", err)
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("logins = %d, want 1", n)
+	}
+}
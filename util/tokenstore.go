@@ -0,0 +1,117 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+)
+
+// tokenFile 保存各个数据源的 token，不再写回配置文件
+var tokenFile = "tokens.json"
+
+/*
+tokens.json
+
+{
+    "jfzt": {
+        "token": "07d8080f-85d7-11ef-8244-1e47b4029c79",
+        "issued_at": "2024-10-08T09:30:00+08:00",
+        "expires_at": "2024-10-09T09:30:00+08:00"
+    }
+}
+*/
+
+// TokenStore 按数据源保存 token。写入时先写临时文件再 rename，文件权限 0600，
+// 读写都加文件锁，同时运行的多个程序不会互相覆盖
+type TokenStore struct {
+	path string
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Load 读取某个数据源的 token
+func (s *TokenStore) Load(provider string) (TokenInfo, bool, error) {
+	lock := flock.New(s.path + ".lock")
+	if err := lock.RLock(); err != nil {
+		return TokenInfo{}, false, err
+	}
+	defer lock.Unlock()
+
+	all, err := s.readAll()
+	if err != nil {
+		return TokenInfo{}, false, err
+	}
+	info, ok := all[provider]
+	return info, ok && info.Token != "", nil
+}
+
+// Save 保存某个数据源的 token，其他数据源的不变
+func (s *TokenStore) Save(provider string, info TokenInfo) error {
+	lock := flock.New(s.path + ".lock")
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	all, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	all[provider] = info
+
+	bt, err := json.MarshalIndent(all, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bt, 0600)
+}
+
+func (s *TokenStore) readAll() (map[string]TokenInfo, error) {
+	all := make(map[string]TokenInfo)
+	bt, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bt) == 0 {
+		return all, nil
+	}
+	if err := json.Unmarshal(bt, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// writeFileAtomic 写到同目录的临时文件，fsync 后 rename 覆盖，中途崩溃不会留下写了一半的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	defer os.Remove(name) // rename 成功后这里什么都不做
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(name, path)
+}
@@ -1,30 +1,28 @@
 package util
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"net/url"
 	"os"
-	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"database/sql"
+	"go-colly/calendar"
+	"go-colly/migrate"
 
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
 
 	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/jedib0t/go-pretty/v6/text"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -32,9 +30,15 @@ var (
 	// Clients and Transports are safe for concurrent use by multiple goroutines and for efficiency should only be created once and re-used.
 	customizedClient = http.Client{Timeout: time.Second * 10}
 	codeFile         = "code.txt"
-	sqlite3db        = "db/stock2.db"
+	configFile       = "watchlist.yaml"
+	sqlite3db        = DefaultSettings.DBPath
 )
 
+// ShanghaiLocation A 股所在时区，系统没有时区数据时退回 UTC+8
+func ShanghaiLocation() *time.Location {
+	return calendar.Location()
+}
+
 // HttpRequest
 // GET:  HttpRequest("http..", "GET", nil, "")
 // POST: HttpRequest("http..", "POST", [content-type=application/x-www-form-urlencoded], "a=1&b=2")
@@ -44,10 +48,54 @@ func HttpRequest(link string, method string, headers map[string]string, body str
 }
 
 func HttpClientRequest(client *http.Client, link string, method string, headers map[string]string, body string) (response string, err error) {
+	return HttpClientRequestContext(context.Background(), client, link, method, headers, body)
+}
+
+// HttpRequestContext 同 HttpRequest，ctx 取消时请求随之中断
+func HttpRequestContext(ctx context.Context, link string, method string, headers map[string]string, body string) (response string, err error) {
+	return HttpClientRequestContext(ctx, &customizedClient, link, method, headers, body)
+}
+
+// HttpClientRequestContext 按 DefaultHostLimiter 限流，按 DefaultRetryPolicy 重试，并按 host 熔断。
+// 返回的错误可以用 errors.Is 判断 ErrTimeout、ErrCircuitOpen 和 &StatusError{Code: xxx}
+func HttpClientRequestContext(ctx context.Context, client *http.Client, link string, method string, headers map[string]string, body string) (response string, err error) {
 	method = strings.ToUpper(method)
-	req, err := http.NewRequest(method, link, strings.NewReader(body))
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("NewRequest error: %w", err)
+	}
+
+	policy := DefaultRetryPolicy
+	for attempt := 0; ; attempt++ {
+		// 先限流再问熔断，Allow 放行的试探请求一定会走到 Record 或 Release
+		if err = DefaultHostLimiter.Wait(ctx, u.Host); err != nil {
+			return "", err
+		}
+		var probe uint64
+		if probe, err = DefaultCircuitBreaker.Allow(u.Host); err != nil {
+			return "", err
+		}
+
+		response, err = doRequest(ctx, client, link, method, headers, body)
+		if ctx.Err() != nil {
+			// 调用方取消或整轮超时，和 host 是否正常无关
+			DefaultCircuitBreaker.Release(u.Host, probe)
+		} else {
+			DefaultCircuitBreaker.Record(u.Host, probe, !breakerFailure(err))
+		}
+		if err == nil || attempt+1 >= policy.MaxAttempts || !policy.shouldRetry(method, err) {
+			return response, err
+		}
+		if sleepContext(ctx, policy.backoff(attempt)) != nil {
+			return response, err
+		}
+	}
+}
+
+func doRequest(ctx context.Context, client *http.Client, link string, method string, headers map[string]string, body string) (response string, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, strings.NewReader(body))
 	if err != nil {
-		return "", errors.New("NewRequest error")
+		return "", fmt.Errorf("NewRequest error: %w", err)
 	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
@@ -55,12 +103,16 @@ func HttpClientRequest(client *http.Client, link string, method string, headers
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", errors.New("client.Do error")
+		return "", &RequestError{Method: method, URL: link, Err: err}
 	}
+	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
-	if err != nil || resp.StatusCode != 200 {
-		return string(data), errors.New(resp.Status)
+	if err != nil {
+		return string(data), &RequestError{Method: method, URL: link, Err: err}
+	}
+	if resp.StatusCode != 200 {
+		return string(data), &StatusError{Code: resp.StatusCode, Status: resp.Status}
 	}
 	return string(data), nil
 }
@@ -134,42 +186,29 @@ func PrettyPrint(v interface{}) {
 	fmt.Println(out.String())
 }
 
+var lastCmdToken string
+
+// GetStockData 获取自选列表的报价，配置文件有修改时自动重新加载
 func GetStockData(cmdToken string) ([]KlineData, error) {
-	conf, err := ParseConfigFile()
+	conf, err := DefaultConfigWatcher.Config()
 	if err != nil {
 		return nil, err
 	}
 
-	if cmdToken != "" {
-		err := StoreTokenToFile(conf.Token, cmdToken)
-		if err != nil {
-			log.Panicln(err)
-		}
-		conf.Token = cmdToken
+	if cmdToken != "" && cmdToken != lastCmdToken {
+		DefaultTokenManager.Set(cmdToken)
+		lastCmdToken = cmdToken
 	}
+	DefaultTokenManager.Seed(conf.Token)
 
-	var result []KlineData
-
-	for _, v := range conf.Stock {
-		code := strings.Split(v, "_")
-		var data KlineData
-		var err error
-		if code[3] == "1" {
-			data, err = GetStockDataFromJFZT(code[0], code[1], conf.Token)
-			if err != nil {
-				log.Println(err)
-			}
-		} else if code[3] == "2" {
-			data, err = GetEtfDataFromJFZT(code[0], code[1])
-		}
-
-		data.StockCode = code[1]
-		data.StockName = code[2]
-
-		result = append(result, data)
+	opts := conf.Settings.FetchOptions()
+	if FetchOverride.Workers > 0 {
+		opts.Workers = FetchOverride.Workers
 	}
-
-	return result, nil
+	if FetchOverride.Deadline > 0 {
+		opts.Deadline = FetchOverride.Deadline
+	}
+	return FetchWatchlist(context.Background(), conf.Watchlist(), opts), nil
 }
 
 /*
@@ -220,6 +259,12 @@ type KlineData struct {
 	SettlementPrice  float64 `json:"SettlementPrice"`
 	StockCode        string
 	StockName        string
+	Symbol           string          // sz002139
+	Stale            bool            `json:"-"`          // 本轮没有拿到，显示的是上一次的数据
+	Failed           bool            `json:"-"`          // 本轮没有拿到，之前也没有成功过，只有代码和名称
+	MAPrice          map[int]float64 `json:",omitempty"` // 均价，key 为周期，如 5 日均价
+	MAVolume         map[int]float64 `json:",omitempty"` // 均量
+	Fundamentals     *Fundamentals   `json:",omitempty"` // 基本面，只有 jfzt-etf 有
 }
 
 /*
@@ -228,68 +273,40 @@ type KlineData struct {
 type RequestData struct {
 	Market      string `json:"Market"`
 	Inst        string `json:"Inst"`
-	Period      string `json:"Period"`
+	Period      Period `json:"Period"`
 	ReqID       int    `json:"ReqID"`
 	Servicetype string `json:"servicetype"`
 	StartID     int    `json:"StartID"`
 	EndID       int    `json:"EndID"`
 }
 
-type Config struct {
-	Stock []string
-	Token string
-}
-
-func StoreTokenToFile(oldtoken, newtoken string) error {
-	bt, err := os.ReadFile(codeFile)
-	os.Truncate(codeFile, 0) // 清空文件内容
-	btstr := string(bt)
-	btstr = strings.ReplaceAll(btstr, oldtoken, newtoken)
-	os.WriteFile(codeFile, []byte(btstr), os.ModePerm)
-
-	return err
+// 九方智投
+func GetStockDataFromJFZT(market string, inst string, token string) (KlineData, error) {
+	return GetStockDataFromJFZTContext(context.Background(), market, inst, token)
 }
 
-func ParseConfigFile() (Config, error) {
-	var conf Config
-	file, err := os.Open(codeFile)
+func GetStockDataFromJFZTContext(ctx context.Context, market string, inst string, token string) (KlineData, error) {
+	list, err := GetKlineDataFromJFZT(ctx, market, inst, token, PeriodDay, 0, -1)
 	if err != nil {
-		return Config{}, err
+		return KlineData{}, err
 	}
-	defer file.Close()
-
-	ids := make([]string, 0)
-	r := bufio.NewReader(file)
-	for {
-		b, _, err := r.ReadLine()
-		if err != nil {
-			break
-		}
-		bs := string(b)
-		if strings.HasPrefix(bs, "//") {
-			continue
-		}
-		if strings.HasPrefix(bs, "token_") {
-			conf.Token = strings.ReplaceAll(bs, "token_", "")
-			continue
-		}
-		ids = append(ids, bs)
+	if len(list) == 0 {
+		return KlineData{}, errors.New("empty KlineData")
 	}
-	conf.Stock = ids
-
-	return conf, nil
+	return list[0], nil
 }
 
-// 九方智投
-func GetStockDataFromJFZT(market string, inst string, token string) (KlineData, error) {
+// GetKlineDataFromJFZT 获取 K 线，最新的在前面。
+// StartID/EndID 从最新一根往前数，0 是最新的一根，EndID 为 -1 时由服务端决定返回多少
+func GetKlineDataFromJFZT(ctx context.Context, market string, inst string, token string, period Period, startID int, endID int) ([]KlineData, error) {
 	req := RequestData{
 		Market:      market,
 		Inst:        inst,
-		Period:      "DAY",
+		Period:      period,
 		ReqID:       1,
 		Servicetype: "KLINE",
-		StartID:     0,
-		EndID:       -1,
+		StartID:     startID,
+		EndID:       endID,
 	}
 	byt, _ := json.Marshal(req)
 
@@ -297,9 +314,9 @@ func GetStockDataFromJFZT(market string, inst string, token string) (KlineData,
 		"token":        token,
 		"content-type": "application/x-www-form-urlencoded",
 	}
-	resp, err := HttpRequest("https://qas.sylapp.cn/api/v30/busi", "POST", header, string(byt))
+	resp, err := HttpRequestContext(ctx, "https://qas.sylapp.cn/api/v30/busi", "POST", header, string(byt))
 	if err != nil {
-		return KlineData{}, nil
+		return nil, err
 	}
 
 	var respData ResponseData
@@ -308,13 +325,13 @@ func GetStockDataFromJFZT(market string, inst string, token string) (KlineData,
 		log.Println(err)
 	}
 	if respData.Code == "0000" {
-		return respData.QuoteData["KlineData"][0], nil
+		return respData.QuoteData["KlineData"], nil
 	} else if respData.Code == "6403" {
 		// token invalid
 		// 访问网站 https://stock.9fzt.com/index/sz_002139.html，从接口 https://qas.sylapp.cn/api/v30/busi 中找到 token ，目前是24小时过期。
-		return KlineData{}, errors.New(respData.Message)
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, respData.Message)
 	} else {
-		return KlineData{}, errors.New(respData.Message)
+		return nil, errors.New(respData.Message)
 	}
 }
 
@@ -394,78 +411,76 @@ func GetStockDataFromJFZT(market string, inst string, token string) (KlineData,
 */
 
 type ResponseDataEtf struct {
-	Code         int                    `json:"Code"`
-	ErrorMessage string                 `json:"errorMessage"`
-	Data         map[string]interface{} `json:"data"`
+	Code         int             `json:"Code"`
+	ErrorMessage string          `json:"errorMessage"`
+	Data         json.RawMessage `json:"data"` // 用 ParseFundamentals 解析
 }
 
 func GetEtfDataFromJFZT(market string, inst string) (KlineData, error) {
-	link := fmt.Sprintf("https://hq.chongnengjihua.com/rjhy-gmg-quote/api/1/stock/getastockfundamentals?symbol=%setf%s", strings.ToLower(market), inst)
-	resp, err := HttpRequest(link, "GET", nil, "")
-	if err != nil {
-		return KlineData{}, nil
-	}
+	return GetEtfDataFromJFZTContext(context.Background(), market, inst)
+}
 
-	var respData ResponseDataEtf
-	err = json.Unmarshal([]byte(resp), &respData)
+// GetEtfDataFromJFZTContext 基本面转成报价，完整的基本面放在 Fundamentals 中
+func GetEtfDataFromJFZTContext(ctx context.Context, market string, inst string) (KlineData, error) {
+	f, err := GetFundamentalsFromJFZT(ctx, market, inst)
 	if err != nil {
-		log.Println(err)
-	}
-	if respData.Code == 0 {
-		preClosePx := respData.Data["preClosePx"].(float64)
-		highPx := respData.Data["highPx"].(float64)
-		lowPx := respData.Data["lowPx"].(float64)
-		closePx := respData.Data["lastPx"].(float64)
-		openPx := respData.Data["openPrice"].(float64)
-		var scale float64 = 0.001
-		da := KlineData{
-			High:     highPx * scale,
-			Low:      lowPx * scale,
-			PreClose: preClosePx * scale,
-			Close:    closePx * scale,
-			Open:     openPx * scale,
-		}
-		return da, nil
-	} else {
-		return KlineData{}, errors.New(respData.ErrorMessage)
+		return KlineData{}, err
 	}
+	da := f.Kline()
+	da.Fundamentals = &f
+	return da, nil
 }
 
 // https://blog.csdn.net/Meepoljd/article/details/129422612
-func BuildTable(result []KlineData) string {
+// BuildTable footer 显示在表格底部，例如自选列表的变化
+func BuildTable(result []KlineData, footer ...string) string {
 	t := table.NewWriter()
-	header := table.Row{"Code", "Name", "Yesterday", "Current", "Open", "High", "Low"}
+	cols := tableColumns(ActiveSettings().Columns, result)
+	theme := ActiveTheme()
+
+	header := make(table.Row, 0, len(cols))
+	for _, c := range cols {
+		header = append(header, c.Header)
+	}
 	t.AppendHeader(header)
 	t.SetAutoIndex(true)
+	setColumnConfigs(t, cols)
 
+	var stale, failed bool
 	for _, v := range result {
-		var curr, high, low, open float64
-		if v.Close > 0 {
-			curr = (v.Close - v.PreClose) / v.PreClose
-		}
-		if v.High > 0 {
-			high = (v.High - v.PreClose) / v.PreClose
-		}
-		if v.Low > 0 {
-			low = (v.Low - v.PreClose) / v.PreClose
+		if v.Stale {
+			v.StockName += " *"
+			stale = true
 		}
-		if v.Open > 0 {
-			open = (v.Open - v.PreClose) / v.PreClose
-		}
-
-		preclode := fmt.Sprintf("%.3f", v.PreClose)
-		currperc := fmt.Sprintf("%.3f [%.2f%%]", v.Close, (math.Round(10000*curr))/100)
-		openperc := fmt.Sprintf("%.3f [%.2f%%]", v.Open, (math.Round(10000*open))/100)
-		highperc := fmt.Sprintf("%.3f [%.2f%%]", v.High, (math.Round(10000*high))/100)
-		lowperc := fmt.Sprintf("%.3f [%.2f%%]", v.Low, (math.Round(10000*low))/100)
-
-		// 样式
-		SetColumnStyle(t, []string{"Current", "Open", "High", "Low"}, nil)
+		failed = failed || v.Failed
 
-		row := table.Row{v.StockCode, v.StockName, preclode, currperc, openperc, highperc, lowperc}
+		row := make(table.Row, 0, len(cols))
+		for _, c := range cols {
+			cell := c.Cell(v)
+			if c.Change != nil && !v.Failed {
+				cell = theme.Paint(cell, c.Change(v))
+			}
+			row = append(row, cell)
+		}
 		t.AppendRow(row)
 	}
 
+	if stale {
+		footer = append(footer, "* stale: last good value, this round timed out or failed")
+	}
+	if failed {
+		footer = append(footer, FailedPlaceholder+" failed: no quote yet")
+	}
+	notes := make([]string, 0, len(footer))
+	for _, v := range footer {
+		if v != "" {
+			notes = append(notes, v)
+		}
+	}
+	if len(notes) > 0 {
+		t.SetCaption(strings.Join(notes, "\n"))
+	}
+
 	return t.Render()
 }
 
@@ -502,95 +517,34 @@ func RefreshTable(data string) string {
 	return dst
 }
 
-// 设置文字为红色和绿色
-func GetColumnTransformer() text.Transformer {
-	warnTransformer := text.Transformer(func(val interface{}) string {
-		WarnColor := text.Colors{text.FgRed}
-		GreenColor := text.Colors{text.FgGreen}
-
-		if strings.Contains(val.(string), "-") {
-			return GreenColor.Sprint(val)
-		} else if strings.Contains(val.(string), "0.00") {
-			return fmt.Sprint(val)
-		} else {
-			return WarnColor.Sprint(val)
-		}
-	})
-
-	return warnTransformer
-}
-
-func SetColumnStyle(t table.Writer, columns []string, warnTransformer text.Transformer) {
-	if len(columns) == 0 {
-		return
-	}
-
-	tableColumnConfig := make([]table.ColumnConfig, 0)
-	for _, column := range columns {
-		tableColumnConfig = append(tableColumnConfig, table.ColumnConfig{
-			Name:        column,
-			AutoMerge:   true,
-			Align:       text.AlignRight,
-			AlignHeader: text.AlignCenter,
-			AlignFooter: text.AlignCenter,
-			Transformer: warnTransformer,
-		})
-	}
+func ParseTokenFromParam() string {
+	var token string
+	flag.StringVar(&token, "token", "", "")
+	flag.Parse()
 
-	t.SetColumnConfigs(tableColumnConfig)
+	return token
 }
 
-func GetTokenFromWebsite() string {
-	jsfile := "https://stock.9fzt.com/_next/static/chunks/pages/index/index/%5Bmarket_symbol%5D-c090f6b2b074bb192ec7.js"
-	resp, err := HttpRequest(jsfile, "GET", nil, "")
+// CreateSqlite3 打开 sqlite3db 并执行未执行的迁移
+func CreateSqlite3() (sqldb *sql.DB, err error) {
+	sqldb, err = OpenSqlite3(sqlite3db)
 	if err != nil {
-		log.Println(err)
-		return ""
+		return nil, err
 	}
-	matches := regexp.MustCompile(`Token:"(.{36})"`).FindStringSubmatch(resp)
-	if len(matches) == 0 {
-		return ""
+	done, err := migrate.Up(sqldb, migrate.SQLite)
+	for _, m := range done {
+		log.Printf("migrated %s to version %d %s", sqlite3db, m.Version, m.Name)
 	}
-
-	pretoken := matches[1]
-	fmt.Println("token1", pretoken)
-
-	headers := make(map[string]string)
-	headers["Content-Type"] = "application/json"
-
-	body := make(map[string]string)
-	body["OrgCode"] = "rh"
-	body["Token"] = pretoken
-	body["AppName"] = "tctest"
-	body["AppVer"] = "V3.1.9"
-	body["AppType"] = "ios"
-	body["Tag"] = "mytag123456"
-	bt, _ := json.Marshal(body)
-
-	rp, err := HttpRequest("https://qas.sylapp.cn/api/v30/login", "POST", headers, string(bt))
 	if err != nil {
-		log.Println(err)
-		return ""
-	}
-	mat := regexp.MustCompile(`"Token":"(.{36})"`).FindStringSubmatch(rp)
-	if len(mat) > 0 {
-		fmt.Println("token2", mat[1])
-		return mat[1]
-	} else {
-		return ""
+		sqldb.Close()
+		return nil, err
 	}
+	return sqldb, nil
 }
 
-func ParseTokenFromParam() string {
-	var token string
-	flag.StringVar(&token, "token", "", "")
-	flag.Parse()
-
-	return token
-}
-
-func CreateSqlite3() (sqldb *sql.DB, err error) {
-	sqldb, err = sql.Open("sqlite3", sqlite3db)
+// OpenSqlite3 只打开数据库，不执行迁移
+func OpenSqlite3(path string) (sqldb *sql.DB, err error) {
+	sqldb, err = sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, errors.New("open database failed: " + err.Error())
 	}
@@ -598,7 +552,7 @@ func CreateSqlite3() (sqldb *sql.DB, err error) {
 	if err != nil {
 		return nil, errors.New("connect database failed: " + err.Error())
 	}
-	fmt.Println("connect to ", sqlite3db, "ok")
+	fmt.Println("connect to ", path, "ok")
 
 	return
 }
@@ -620,8 +574,9 @@ func CheckAndMakeDirAll(filepath string) (err error) {
 	return nil
 }
 
-// OutPutDataWithXLSX output struct slice list to xlsx with specified style
-func OutPutDataWithXLSX(list interface{}, headers []string, title string, filepath string, filename string, mergenum int) (err error) {
+// OutPutDataWithXLSX output rows to xlsx with specified style, each row has one value per header, nil for an empty cell.
+// 第一列相同的连续行为一组，前 mergeCols 列在组内合并，如同一只股票的 ID、名称
+func OutPutDataWithXLSX(rows [][]interface{}, headers []string, title string, filepath string, filename string, mergeCols int) (err error) {
 	// 创建目录结构
 	err = CheckAndMakeDirAll(filepath)
 	if err != nil {
@@ -636,15 +591,19 @@ func OutPutDataWithXLSX(list interface{}, headers []string, title string, filepa
 	sheet1 := "Sheet1"
 	f := excelize.NewFile()
 
-	// 65 -> A
-	ln := len(headers)
-	character := ""
-	if ln <= 26 {
-		character = string(64 + ln)
-	} else if ln <= 52 {
-		character = "A" + string(64+ln-26)
-	} else {
-		character = "AA" + string(64+ln-52)
+	if len(headers) == 0 {
+		return errors.New("no headers")
+	}
+	// 先检查所有行，分组时会读取下一行的第一列
+	for i, row := range rows {
+		if len(row) != len(headers) {
+			return fmt.Errorf("row %d has %d values, want %d", i+1, len(row), len(headers))
+		}
+	}
+	// 最后一列的列名
+	character, err := excelize.ColumnNumberToName(len(headers))
+	if err != nil {
+		return err
 	}
 
 	/* -------------------- 第一行大标题 -------------------- */
@@ -686,15 +645,8 @@ func OutPutDataWithXLSX(list interface{}, headers []string, title string, filepa
 	}
 
 	for k, v := range headers {
-		chr := ""
-		if k < 26 {
-			chr = string(64 + k + 1)
-		} else if k < 52 {
-			chr = "A" + string(64+k+1-26)
-		} else {
-			chr = "AA" + string(64+k+1-52)
-		}
-		err = f.SetCellValue(sheet1, chr+"2", v)
+		cell, _ := excelize.CoordinatesToCellName(k+1, 2)
+		err = f.SetCellValue(sheet1, cell, v)
 		if err != nil {
 			fmt.Println("66")
 			return err
@@ -717,67 +669,41 @@ func OutPutDataWithXLSX(list interface{}, headers []string, title string, filepa
 
 	///* -------------------- 填充行数据 -------------------- */
 
-	getValue := reflect.ValueOf(list)
-	if getValue.Kind() != reflect.Slice {
-		return errors.New("list must be slice")
-	}
-	length := getValue.Len()
+	group := 0 // 当前这一组的第一行
+	for i, row := range rows {
+		line := i + 3
+		lineChr := strconv.Itoa(line)
+		// 设置样式
+		err = f.SetCellStyle(sheet1, "A"+lineChr, character+lineChr, styleHeader)
+		if err != nil {
+			fmt.Println("99")
+			return err
+		}
 
-	if length > 0 {
-		line := 3
-		for i := 0; i < length; i++ {
-			value := getValue.Index(i)
-			typel := value.Type()
-			if typel.Kind() != reflect.Struct {
-				return errors.New("list must be slice of struct")
+		for k, v := range row {
+			if v == nil {
+				continue
 			}
-
-			lineChr := strconv.Itoa(line)
-			// 设置样式
-			err = f.SetCellStyle(sheet1, "A"+lineChr, character+lineChr, styleHeader)
+			cell, _ := excelize.CoordinatesToCellName(k+1, line)
+			err = f.SetCellValue(sheet1, cell, v)
 			if err != nil {
-				fmt.Println("99")
+				fmt.Println("00")
 				return err
 			}
+		}
 
-			n := value.NumField()
-			var v0, v1 interface{}
-			for i := 0; i < n; i++ {
-				val := value.Field(i)
-
-				if i == 0 {
-					v0 = val.Interface()
-				}
-				if i == 1 {
-					v1 = val.Interface()
-				}
-
-				chr := ""
-				if i < 26 {
-					chr = string(64 + i + 1)
-				} else if i < 52 {
-					chr = "A" + string(64+i+1-26)
-				} else {
-					chr = "AA" + string(64+i+1-52)
-				}
-
-				err = f.SetCellValue(sheet1, chr+lineChr, val.Interface())
-				if err != nil {
-					fmt.Println("00")
-					return err
-				}
-			}
-
-			// i = 0,8; 9,17
-			if (i+1)%mergenum == 0 {
-				f.MergeCell(sheet1, "A"+strconv.Itoa(i-mergenum+4), "A"+strconv.Itoa(i+3))
-				f.SetCellValue(sheet1, "A"+strconv.Itoa(i-mergenum+4), v0)
-				f.MergeCell(sheet1, "B"+strconv.Itoa(i-mergenum+4), "B"+strconv.Itoa(i+3))
-				f.SetCellValue(sheet1, "B"+strconv.Itoa(i-mergenum+4), v1)
+		// 下一行是另一组或者已经是最后一行时，合并这一组
+		if i+1 < len(rows) && rows[i+1][0] == row[0] {
+			continue
+		}
+		if i > group {
+			for k := 0; k < mergeCols && k < len(headers); k++ {
+				top, _ := excelize.CoordinatesToCellName(k+1, group+3)
+				bottom, _ := excelize.CoordinatesToCellName(k+1, line)
+				f.MergeCell(sheet1, top, bottom)
 			}
-
-			line++
 		}
+		group = i + 1
 	}
 
 	err = f.SaveAs(filename)